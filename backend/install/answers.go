@@ -0,0 +1,224 @@
+package install
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// JavaPolicy decides what the installer does about Java on windows
+type JavaPolicy string
+
+// The Java policies
+const (
+	JavaInstall JavaPolicy = "install" // install Java if it's missing
+	JavaRequire JavaPolicy = "require" // fail if Java is missing
+	JavaSkip    JavaPolicy = "skip"    // don't check for Java
+)
+
+// ShortcutOptions select the shortcuts created on windows
+type ShortcutOptions struct {
+	Desktop   bool `yaml:"desktop"`
+	StartMenu bool `yaml:"start_menu"`
+}
+
+// installOptions are the choices of an installation the GUI doesn't offer, they're set from the
+// answers of a silent installation
+type installOptions struct {
+	version   string // the version to install, the latest release on the channel if empty
+	shortcuts ShortcutOptions
+	java      JavaPolicy
+	keepData  bool // keep the contents of the install folder instead of emptying it
+	launch    bool
+}
+
+func defaultInstallOptions() installOptions {
+	return installOptions{
+		shortcuts: ShortcutOptions{Desktop: true, StartMenu: true},
+		java:      JavaInstall,
+		launch:    true,
+	}
+}
+
+// Answers configure an unattended installation, see SilentInstall. They're read from a YAML or
+// JSON file with the same snake_case keys, e.g
+//
+//	version: 1.2.0        # the latest release on the channel if empty
+//	channel: stable
+//	install_dir: ~/.dag
+//	shortcuts:
+//	  desktop: true
+//	  start_menu: true
+//	java: install         # install, require or skip
+//	keep_data: true       # keep the contents of the install folder
+//	close_running: true   # stop a running Molly Wallet instead of failing
+//	launch: false         # off by default, unlike the GUI which launches the wallet
+//	self_update: false    # let the installer update itself first
+type Answers struct {
+	Version      string          `yaml:"version"`
+	Channel      string          `yaml:"channel"`
+	InstallDir   string          `yaml:"install_dir"`
+	Shortcuts    ShortcutOptions `yaml:"shortcuts"`
+	Java         JavaPolicy      `yaml:"java"`
+	KeepData     bool            `yaml:"keep_data"`
+	CloseRunning bool            `yaml:"close_running"`
+	Launch       bool            `yaml:"launch"`
+	// SelfUpdate lets the installer replace itself with its latest release before installing.
+	// It's off by default, so every machine of a rollout runs the same installer.
+	SelfUpdate bool `yaml:"self_update"`
+}
+
+// LoadAnswers reads and validates the answers file at path. Unknown keys are rejected, so a typo
+// doesn't silently fall back to a default.
+func LoadAnswers(path string) (*Answers, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the answers file: %v", err)
+	}
+
+	a := &Answers{
+		Shortcuts: ShortcutOptions{Desktop: true, StartMenu: true},
+		Java:      JavaInstall,
+	}
+	// JSON is valid YAML, so one decoder reads both
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	err = dec.Decode(a)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the answers file %s: %v", path, err)
+	}
+
+	err = a.validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid answers file %s: %v", path, err)
+	}
+	return a, nil
+}
+
+func (a *Answers) validate() error {
+	if a.Version != "" {
+		a.Version = strings.TrimPrefix(a.Version, "v")
+		if _, err := semver.NewVersion(a.Version); err != nil {
+			return fmt.Errorf("version %q: %v", a.Version, err)
+		}
+	}
+	if a.Channel != "" {
+		channel, err := parseChannel(a.Channel)
+		if err != nil {
+			return err
+		}
+		a.Channel = string(channel)
+	}
+	switch a.Java {
+	case JavaInstall, JavaRequire, JavaSkip:
+	default:
+		return fmt.Errorf("unknown java policy %q, expected install, require or skip", a.Java)
+	}
+	if strings.HasPrefix(a.InstallDir, "~") {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return fmt.Errorf("unable to locate users home directory: %v", err)
+		}
+		a.InstallDir = filepath.Join(homeDir, strings.TrimPrefix(a.InstallDir, "~"))
+	}
+	return nil
+}
+
+// InstallSummary is the outcome of an unattended installation
+type InstallSummary struct {
+	State      State
+	Version    string
+	Channel    string
+	Arch       string
+	InstallDir string
+	Launched   bool
+	Duration   time.Duration
+	Warnings   []string
+	Error      string
+}
+
+// Summary describes the outcome for the command line
+func (s *InstallSummary) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "State:       %s\n", s.State)
+	fmt.Fprintf(&b, "Version:     %s (%s channel)\n", s.Version, s.Channel)
+	if s.Arch != "" {
+		fmt.Fprintf(&b, "Arch:        %s\n", s.Arch)
+	}
+	fmt.Fprintf(&b, "Install dir: %s\n", s.InstallDir)
+	fmt.Fprintf(&b, "Launched:    %t\n", s.Launched)
+	fmt.Fprintf(&b, "Duration:    %s\n", s.Duration.Round(time.Second))
+	for _, w := range s.Warnings {
+		fmt.Fprintf(&b, "Warning:     %s\n", w)
+	}
+	if s.Error != "" {
+		fmt.Fprintf(&b, "Error:       %s\n", s.Error)
+	}
+	return b.String()
+}
+
+// SilentInstall runs an installation configured by the answers, without ever prompting, and
+// returns its summary. The install folder of the answers has to be passed to Init through
+// Config.InstallDir.
+func (i *Install) SilentInstall(ctx context.Context, a *Answers) (*InstallSummary, error) {
+	if a.Channel != "" {
+		err := i.SetChannel(a.Channel)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if a.CloseRunning {
+		i.SetCloseRunningApps(true)
+	}
+
+	i.mu.Lock()
+	i.options = installOptions{
+		version:   a.Version,
+		shortcuts: a.Shortcuts,
+		java:      a.Java,
+		keepData:  a.KeepData,
+		launch:    a.Launch,
+	}
+	i.mu.Unlock()
+	defer func() {
+		i.mu.Lock()
+		i.options = defaultInstallOptions()
+		i.mu.Unlock()
+	}()
+
+	start := time.Now()
+	i.Run(ctx)
+
+	state := i.GetState()
+	s := &InstallSummary{
+		State:      state.State,
+		Version:    a.Version,
+		Channel:    i.GetChannel(),
+		InstallDir: i.dagFolderPath,
+		Duration:   time.Since(start),
+		Error:      state.Error,
+	}
+	launchFailed := false
+	for _, w := range i.runWarnings() {
+		s.Warnings = append(s.Warnings, w.title+": "+w.msg)
+		launchFailed = launchFailed || w.code == codeLaunch
+	}
+	if state.State != StateDone {
+		return s, fmt.Errorf("installation ended in state %s: %s", state.State, state.Error)
+	}
+	if m, err := i.readManifest(); err == nil {
+		s.Version, s.Arch = m.Version, m.Arch
+	}
+	s.Launched = a.Launch && !launchFailed
+	log.Infof("Unattended installation complete:\n%s", s.Summary())
+	return s, nil
+}
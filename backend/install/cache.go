@@ -0,0 +1,278 @@
+package install
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultCacheLimit is the size the download cache is pruned to, unless Config.CacheLimit says
+// otherwise
+const defaultCacheLimit = 1 << 30
+
+// downloadCache keeps verified downloads by their SHA-256, so reinstalling a release doesn't
+// download it again. The package is looked up by the checksum of the release. Downloads whose
+// URL always serves the same file, like the versioned wallet SDK jars, are also indexed by URL.
+// Once the cache grows beyond its limit the least recently used files are evicted.
+type downloadCache struct {
+	mu    sync.Mutex
+	dir   string // empty disables the cache
+	limit int64
+}
+
+func newDownloadCache(dir string, limit int64) *downloadCache {
+	if limit < 0 {
+		dir = ""
+	}
+	return &downloadCache{dir: dir, limit: limit}
+}
+
+// downloadCacheDir returns the folder downloads are cached in, empty if cacheDir is
+func downloadCacheDir(cacheDir string) string {
+	if cacheDir == "" {
+		return ""
+	}
+	return filepath.Join(cacheDir, "downloads")
+}
+
+func (c *downloadCache) enabled() bool {
+	return c.dir != ""
+}
+
+func (c *downloadCache) blobDir() string {
+	return filepath.Join(c.dir, "sha256")
+}
+
+func (c *downloadCache) blobPath(sum string) string {
+	return filepath.Join(c.blobDir(), sum)
+}
+
+func (c *downloadCache) indexPath() string {
+	return filepath.Join(c.dir, "index.json")
+}
+
+// loadIndex returns the SHA-256 of the cached downloads by URL, a missing or invalid index is
+// treated as empty
+func (c *downloadCache) loadIndex() map[string]string {
+	index := map[string]string{}
+	data, err := ioutil.ReadFile(c.indexPath())
+	if err == nil && json.Unmarshal(data, &index) != nil {
+		log.Warnf("Ignoring the invalid download cache index %s", c.indexPath())
+		return map[string]string{}
+	}
+	return index
+}
+
+func (c *downloadCache) saveIndex(index map[string]string) error {
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := c.indexPath() + ".tmp"
+	err = ioutil.WriteFile(tmp, data, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.indexPath())
+}
+
+// lookup returns the SHA-256 of the cached download of url, empty if there is none
+func (c *downloadCache) lookup(url string) string {
+	if !c.enabled() {
+		return ""
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.loadIndex()[url]
+}
+
+// restore copies the cached file with the given SHA-256 to dst and reports whether it was
+// cached. A cached file that doesn't match its SHA-256 anymore is evicted.
+func (c *downloadCache) restore(sum, dst string) bool {
+	if !c.enabled() || sum == "" {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	src := c.blobPath(sum)
+	actual, err := fileSHA256(src)
+	if os.IsNotExist(err) {
+		return false
+	}
+	if err != nil || actual != sum {
+		log.Warnf("Evicting the corrupted cached download %s", src)
+		os.Remove(src)
+		return false
+	}
+
+	err = copyFile(src, dst, dataFileMode)
+	if err != nil {
+		log.Warnf("Unable to restore the cached download %s: %v", src, err)
+		return false
+	}
+	// the modification time tracks the last use for evictions
+	now := time.Now()
+	os.Chtimes(src, now, now)
+	return true
+}
+
+// store adds the file at filePath to the cache, indexed by url unless it's empty, and prunes the
+// cache to its limit. Failures only cost a download next time, so they're logged.
+func (c *downloadCache) store(url, filePath string) {
+	if !c.enabled() {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	err := c.storeFile(url, filePath)
+	if err != nil {
+		log.Warnf("Unable to cache %s: %v", filePath, err)
+		return
+	}
+	err = c.prune()
+	if err != nil {
+		log.Warnf("Unable to prune the download cache: %v", err)
+	}
+}
+
+func (c *downloadCache) storeFile(url, filePath string) error {
+	sum, err := fileSHA256(filePath)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(c.blobDir(), 0700)
+	if err != nil {
+		return err
+	}
+	if !fileExists(c.blobPath(sum)) {
+		err = copyFile(filePath, c.blobPath(sum), dataFileMode)
+		if err != nil {
+			return err
+		}
+		log.Infof("Cached %s as %s", filePath, sum)
+	}
+	if url == "" {
+		return nil
+	}
+	index := c.loadIndex()
+	index[url] = sum
+	return c.saveIndex(index)
+}
+
+// prune evicts the least recently used files until the cache fits its limit and drops the index
+// entries of evicted files
+func (c *downloadCache) prune() error {
+	entries, err := c.entries()
+	if err != nil {
+		return err
+	}
+	sort.Slice(entries, func(a, b int) bool {
+		return entries[a].ModTime().After(entries[b].ModTime())
+	})
+
+	var size int64
+	for _, entry := range entries {
+		size += entry.Size()
+		if size <= c.limit {
+			continue
+		}
+		log.Infof("Evicting %s (%s) from the download cache", entry.Name(), formatBytes(entry.Size()))
+		err = os.Remove(c.blobPath(entry.Name()))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	index := c.loadIndex()
+	for url, sum := range index {
+		if !fileExists(c.blobPath(sum)) {
+			delete(index, url)
+		}
+	}
+	return c.saveIndex(index)
+}
+
+// entries returns the cached files
+func (c *downloadCache) entries() ([]os.FileInfo, error) {
+	infos, err := ioutil.ReadDir(c.blobDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []os.FileInfo
+	for _, info := range infos {
+		if info.Mode().IsRegular() && !isTmpName(info.Name()) {
+			entries = append(entries, info)
+		}
+	}
+	return entries, nil
+}
+
+// isTmpName reports whether name is a temporary file of copyFile
+func isTmpName(name string) bool {
+	return name != "" && name[0] == '.'
+}
+
+// CacheUsage describes the files in the download cache
+type CacheUsage struct {
+	Dir   string `json:"dir"`
+	Files int    `json:"files"`
+	Size  int64  `json:"size"`
+}
+
+// Summary describes the usage for the command line
+func (u *CacheUsage) Summary() string {
+	return fmt.Sprintf("%d file(s), %s in %s", u.Files, formatBytes(u.Size), u.Dir)
+}
+
+// clean removes every cached download and returns what was removed
+func (c *downloadCache) clean() (*CacheUsage, error) {
+	usage := &CacheUsage{Dir: c.dir}
+	if !c.enabled() {
+		return usage, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries, err := c.entries()
+	if err != nil {
+		return usage, err
+	}
+	for _, entry := range entries {
+		usage.Files++
+		usage.Size += entry.Size()
+	}
+	err = removeFolders([]string{c.dir})
+	if err != nil {
+		return usage, fmt.Errorf("unable to remove the download cache: %v", err)
+	}
+	return usage, nil
+}
+
+// CleanCache removes every cached download and returns what was removed. It can't run while an
+// installation is running.
+func (i *Install) CleanCache() (*CacheUsage, error) {
+	err := i.begin("cache cleanup")
+	if err != nil {
+		return nil, err
+	}
+	defer i.end()
+
+	usage, err := i.cache.clean()
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Cleaned the download cache: %s", usage.Summary())
+	return usage, nil
+}
@@ -0,0 +1,173 @@
+package install
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	log "github.com/sirupsen/logrus"
+)
+
+// Channel selects the releases the installer installs from
+type Channel string
+
+// The release channels, every channel includes the releases of the channels above it
+const (
+	ChannelStable  Channel = "stable"  // published releases
+	ChannelBeta    Channel = "beta"    // GitHub prereleases
+	ChannelNightly Channel = "nightly" // prereleases tagged nightly, e.g v1.3.0-nightly.20201015-linux
+)
+
+// preferencesFileName holds the user's installer preferences in the .dag folder, it survives
+// reinstalls
+const preferencesFileName = "installer_preferences.json"
+
+// parseChannel returns the channel called name
+func parseChannel(name string) (Channel, error) {
+	switch c := Channel(strings.ToLower(strings.TrimSpace(name))); c {
+	case ChannelStable, ChannelBeta, ChannelNightly:
+		return c, nil
+	}
+	return "", fmt.Errorf("unknown release channel %q, expected one of stable, beta or nightly", name)
+}
+
+// includes reports whether r is published on channel c
+func (c Channel) includes(r *release) bool {
+	if r.Draft {
+		return false
+	}
+	nightly := strings.Contains(strings.ToLower(r.TagName), "nightly")
+	switch c {
+	case ChannelStable:
+		return !r.Prerelease && !nightly
+	case ChannelBeta:
+		return !nightly
+	}
+	return true
+}
+
+// preferences are the user's installer settings stored in the .dag folder
+type preferences struct {
+	Channel Channel `json:"channel"`
+}
+
+func (i *Install) preferencesPath() string {
+	return path.Join(i.dagFolderPath, preferencesFileName)
+}
+
+// loadPreferences reads the stored preferences, missing or invalid preferences fall back to
+// the defaults
+func (i *Install) loadPreferences() *preferences {
+	p := &preferences{Channel: ChannelStable}
+
+	data, err := ioutil.ReadFile(i.preferencesPath())
+	if os.IsNotExist(err) {
+		return p
+	}
+	if err == nil {
+		err = json.Unmarshal(data, p)
+	}
+	if err == nil {
+		p.Channel, err = parseChannel(string(p.Channel))
+	}
+	if err != nil {
+		log.Warnf("Ignoring invalid installer preferences: %v", err)
+		return &preferences{Channel: ChannelStable}
+	}
+	return p
+}
+
+func (i *Install) savePreferences(p *preferences) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(i.dagFolderPath, os.FileMode(0744))
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(i.preferencesPath(), data, dataFileMode)
+}
+
+// GetChannel returns the release channel the next installation installs from
+func (i *Install) GetChannel() string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return string(i.channel)
+}
+
+// SetChannel selects the release channel and stores the choice in the .dag folder. It can't be
+// changed while an installation is running.
+func (i *Install) SetChannel(name string) error {
+	channel, err := parseChannel(name)
+	if err != nil {
+		return err
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.busy != "" {
+		return fmt.Errorf("unable to change the release channel while the %s is running", i.busy)
+	}
+	err = i.savePreferences(&preferences{Channel: channel})
+	if err != nil {
+		return fmt.Errorf("unable to store the release channel: %v", err)
+	}
+	if channel != i.channel {
+		log.Infof("Release channel changed from %s to %s", i.channel, channel)
+		i.resetRelease()
+	}
+	i.channel = channel
+	return nil
+}
+
+// channelRelease returns the newest release for osBuild on channel. Stable releases are looked
+// up through the latest release, the other channels search the most recent 100 releases.
+func (c *githubClient) channelRelease(ctx context.Context, channel Channel, osBuild string) (*release, error) {
+	if channel == ChannelStable {
+		return c.latestRelease(ctx)
+	}
+
+	list, err := c.releases(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var newest *release
+	var newestVersion *semver.Version
+	for n := range list {
+		r := &list[n]
+		if !strings.HasSuffix(r.TagName, "-"+osBuild) || !channel.includes(r) {
+			continue
+		}
+		version, err := releaseVersion(r.TagName)
+		if err != nil {
+			log.Warnf("Skipping release %s: %v", r.TagName, err)
+			continue
+		}
+		v, _ := semver.NewVersion(version)
+		if newest == nil || v.GreaterThan(newestVersion) {
+			newest, newestVersion = r, v
+		}
+	}
+	if newest == nil {
+		return nil, &releaseNotFoundError{channel: channel}
+	}
+	return newest, nil
+}
+
+// releases returns the most recent releases, including prereleases
+func (c *githubClient) releases(ctx context.Context) ([]release, error) {
+	var list []release
+	err := c.get(ctx, c.baseURL+"?per_page=100", &list)
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
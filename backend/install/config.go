@@ -0,0 +1,137 @@
+package install
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Config holds the locations the installer works in. Empty fields take their default.
+type Config struct {
+	// InstallDir is the folder Molly Wallet is installed to, ~/.dag by default
+	InstallDir string
+	// TmpDir is the folder the private temporary folder of each run is created in, the
+	// system temp folder by default
+	TmpDir string
+	// CacheDir is the folder for cached GitHub API responses and downloads,
+	// <user cache dir>/molly_installer by default
+	CacheDir string
+	// CacheLimit is the size in bytes the download cache is pruned to. $MOLLY_CACHE_LIMIT_MB or
+	// 1 GiB by default, negative disables the download cache.
+	CacheLimit int64
+	// GitHubToken authenticates GitHub API requests, which raises the rate limit. Taken from
+	// $MOLLY_GITHUB_TOKEN or $GITHUB_TOKEN by default, requests are anonymous without one.
+	GitHubToken string
+
+	// ProxyURL is the proxy all requests go through, $MOLLY_PROXY by default. Without one the
+	// proxy is taken from $HTTPS_PROXY, $HTTP_PROXY and $NO_PROXY.
+	ProxyURL string
+	// ProxyUsername and ProxyPassword authenticate with the proxy, $MOLLY_PROXY_USER and
+	// $MOLLY_PROXY_PASSWORD by default
+	ProxyUsername string
+	ProxyPassword string
+	// CABundle is a PEM file of certificates trusted in addition to the system ones, e.g the
+	// certificate of a TLS intercepting gateway. $MOLLY_CA_BUNDLE by default.
+	CABundle string
+	// MinTLSVersion is the lowest TLS version accepted, 1.2 or 1.3. $MOLLY_MIN_TLS_VERSION or
+	// 1.2 by default.
+	MinTLSVersion string
+}
+
+// withDefaults returns a copy of c with the empty fields set to their default and all paths
+// made absolute
+func (c Config) withDefaults() (Config, error) {
+	if c.InstallDir == "" {
+		userHomeDir, err := os.UserHomeDir()
+		if err != nil {
+			return c, fmt.Errorf("unable to locate users home directory: %v", err)
+		}
+		c.InstallDir = filepath.Join(userHomeDir, ".dag")
+	}
+	if c.TmpDir == "" {
+		c.TmpDir = os.TempDir()
+	}
+	if c.CacheDir == "" {
+		cacheDir, err := os.UserCacheDir()
+		if err != nil {
+			// not fatal, API responses just aren't cached
+			log.Warnf("Unable to locate the user cache directory: %v", err)
+		} else {
+			c.CacheDir = filepath.Join(cacheDir, "molly_installer")
+		}
+	}
+	if c.CacheLimit == 0 {
+		if mb := os.Getenv("MOLLY_CACHE_LIMIT_MB"); mb != "" {
+			limit, err := strconv.ParseInt(mb, 10, 64)
+			if err != nil {
+				return c, fmt.Errorf("invalid MOLLY_CACHE_LIMIT_MB: %v", err)
+			}
+			c.CacheLimit = limit << 20
+		}
+	}
+	if c.CacheLimit == 0 {
+		c.CacheLimit = defaultCacheLimit
+	}
+	if c.GitHubToken == "" {
+		c.GitHubToken = os.Getenv("MOLLY_GITHUB_TOKEN")
+	}
+	if c.GitHubToken == "" {
+		c.GitHubToken = os.Getenv("GITHUB_TOKEN")
+	}
+	if c.ProxyURL == "" {
+		c.ProxyURL = os.Getenv("MOLLY_PROXY")
+	}
+	if c.ProxyUsername == "" {
+		c.ProxyUsername = os.Getenv("MOLLY_PROXY_USER")
+	}
+	if c.ProxyPassword == "" {
+		c.ProxyPassword = os.Getenv("MOLLY_PROXY_PASSWORD")
+	}
+	if c.CABundle == "" {
+		c.CABundle = os.Getenv("MOLLY_CA_BUNDLE")
+	}
+	if c.MinTLSVersion == "" {
+		c.MinTLSVersion = os.Getenv("MOLLY_MIN_TLS_VERSION")
+	}
+	if c.MinTLSVersion == "" {
+		c.MinTLSVersion = "1.2"
+	}
+
+	var err error
+	c.InstallDir, err = filepath.Abs(c.InstallDir)
+	if err != nil {
+		return c, fmt.Errorf("unable to resolve the install folder: %v", err)
+	}
+	c.TmpDir, err = filepath.Abs(c.TmpDir)
+	if err != nil {
+		return c, fmt.Errorf("unable to resolve the temp folder: %v", err)
+	}
+	if c.CacheDir != "" {
+		c.CacheDir, err = filepath.Abs(c.CacheDir)
+		if err != nil {
+			return c, fmt.Errorf("unable to resolve the cache folder: %v", err)
+		}
+	}
+	return c, nil
+}
+
+// makeTmpFolder creates a new private temporary folder for the extracted package and the
+// backups of the current run. It's removed again by CleanUp.
+func (i *Install) makeTmpFolder() error {
+	if i.tmpFolderPath != "" {
+		os.RemoveAll(i.tmpFolderPath)
+	}
+	err := os.MkdirAll(i.config.TmpDir, 0755)
+	if err != nil {
+		return fmt.Errorf("unable to create the temp folder: %v", err)
+	}
+	dir, err := os.MkdirTemp(i.config.TmpDir, "molly-install-")
+	if err != nil {
+		return fmt.Errorf("unable to create the temp folder: %v", err)
+	}
+	i.tmpFolderPath = dir
+	return nil
+}
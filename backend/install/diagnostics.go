@@ -0,0 +1,146 @@
+package install
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// secretPattern matches key/value pairs that may carry credentials, the value is redacted
+var secretPattern = regexp.MustCompile(`(?i)("?(?:password|passphrase|passwd|token|secret|private[_ ]?key|authorization|api[_-]?key)"?\s*[:=]\s*)("[^"]*"|\S+)`)
+
+// githubTokenPattern matches GitHub personal access tokens anywhere in the text
+var githubTokenPattern = regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{20,}|github_pat_[A-Za-z0-9_]{20,})\b`)
+
+// ExportDiagnostics bundles the install logs, the wallet logs, the OS specific settings and the
+// install manifest into a zip archive for bug reports. Home directory paths and secrets are
+// redacted. If dst is empty the bundle is written to the users home directory.
+// Returns the path to the bundle.
+func (i *Install) ExportDiagnostics(dst string) (string, error) {
+	homeDir, _ := os.UserHomeDir()
+
+	if dst == "" {
+		dst = filepath.Join(homeDir, "molly_diagnostics_"+time.Now().Format("20060102_150405")+".zip")
+	}
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return "", fmt.Errorf("unable to create diagnostics bundle: %v", err)
+	}
+	defer out.Close()
+
+	zw := zip.NewWriter(out)
+	r := &redactor{homeDir: homeDir}
+
+	for _, logFile := range logFiles() {
+		err = addDiagnosticsFile(zw, r, logFile, path.Join("logs", filepath.Base(logFile)))
+		if err != nil {
+			return "", err
+		}
+	}
+	for _, logFile := range []string{"wallet.log", "update.log"} {
+		err = addDiagnosticsFile(zw, r, path.Join(i.dagFolderPath, logFile), path.Join("wallet", logFile))
+		if err != nil {
+			return "", err
+		}
+	}
+	err = addDiagnosticsFile(zw, r, i.manifestPath(), manifestFileName)
+	if err != nil {
+		return "", err
+	}
+
+	settings, err := json.MarshalIndent(i.diagnosticsSettings(), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	err = addDiagnosticsData(zw, "settings.json", r.redact(settings))
+	if err != nil {
+		return "", err
+	}
+
+	if err = zw.Close(); err != nil {
+		return "", fmt.Errorf("unable to write diagnostics bundle: %v", err)
+	}
+	if err = out.Close(); err != nil {
+		return "", fmt.Errorf("unable to write diagnostics bundle: %v", err)
+	}
+
+	log.Infof("Exported diagnostics bundle to %s", dst)
+	return dst, nil
+}
+
+// diagnosticsSettings describes the environment of the installer
+func (i *Install) diagnosticsSettings() map[string]interface{} {
+	return map[string]interface{}{
+		"run_id":          RunID(),
+		"installer":       Version,
+		"relaunched":      Relaunched(),
+		"os":              runtime.GOOS,
+		"arch":            runtime.GOARCH,
+		"go_version":      runtime.Version(),
+		"os_build":        i.OSSpecificSettings.osBuild,
+		"binary_path":     i.OSSpecificSettings.binaryPath,
+		"shortcut_path":   i.OSSpecificSettings.shortcutPath,
+		"start_menu_path": i.OSSpecificSettings.startMenuPath,
+		"desktop_path":    i.OSSpecificSettings.desktopPath,
+		"dag_folder_path": i.dagFolderPath,
+		"tmp_folder_path": i.config.TmpDir,
+		"download_url":    i.downloadURL,
+		"cache_dir":       i.config.CacheDir,
+		"cache_limit":     i.config.CacheLimit,
+		"github_token":    i.config.GitHubToken != "",
+		"proxy":           i.config.proxyDescription(),
+		"ca_bundle":       i.config.CABundle,
+		"min_tls_version": i.config.MinTLSVersion,
+		"user_agent":      userAgent(),
+	}
+}
+
+// addDiagnosticsFile adds the redacted content of src to the bundle, missing files are skipped
+func addDiagnosticsFile(zw *zip.Writer, r *redactor, src, name string) error {
+	data, err := ioutil.ReadFile(src)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		log.Warnf("Unable to add %s to the diagnostics bundle: %v", src, err)
+		return nil
+	}
+	return addDiagnosticsData(zw, name, r.redact(data))
+}
+
+func addDiagnosticsData(zw *zip.Writer, name string, data []byte) error {
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return fmt.Errorf("unable to add %s to the diagnostics bundle: %v", name, err)
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// redactor strips personal information and secrets from diagnostics files
+type redactor struct {
+	homeDir string
+}
+
+func (r *redactor) redact(data []byte) []byte {
+	s := string(data)
+	if r.homeDir != "" {
+		for _, home := range []string{r.homeDir, filepath.ToSlash(r.homeDir), strings.Replace(r.homeDir, `\`, `\\`, -1)} {
+			s = strings.Replace(s, home, "~", -1)
+		}
+	}
+	s = secretPattern.ReplaceAllString(s, "${1}[REDACTED]")
+	s = githubTokenPattern.ReplaceAllString(s, "[REDACTED]")
+	return []byte(s)
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+// +build !windows
+
+package install
+
+import "syscall"
+
+// freeDiskSpace returns the number of bytes available to the user on the volume of dir
+func freeDiskSpace(dir string) (uint64, error) {
+	var st syscall.Statfs_t
+	err := syscall.Statfs(dir, &st)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), nil
+}
@@ -0,0 +1,244 @@
+package install
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// maxParallelDownloads bounds the number of files downloaded at the same time
+	maxParallelDownloads = 3
+
+	// downloadProgressInterval is how often the combined progress of the downloads is reported
+	downloadProgressInterval = 500 * time.Millisecond
+)
+
+// download is a file fetched by downloadAll
+type download struct {
+	name     string // shown to the user
+	url      string
+	filePath string
+	optional bool   // a failure is reported, but doesn't stop the other downloads
+	sha256   string // the expected SHA-256, if known, to look the file up in the download cache
+	cacheURL bool   // the URL always serves the same file, so it's cached by URL
+
+	written int64 // bytes written by the current attempt
+	size    int64 // zero if unknown
+	done    bool
+}
+
+// releaseDownloads returns the files of the resolved release to download: the package, its
+// checksum and the wallet SDK, in that order
+func (i *Install) releaseDownloads() ([]*download, error) {
+	asset, err := i.packageAsset()
+	if err != nil {
+		return nil, err
+	}
+
+	downloads := []*download{
+		{
+			name:     asset.Name,
+			url:      asset.BrowserDownloadURL,
+			filePath: path.Join(i.dagFolderPath, asset.Name),
+			size:     asset.Size,
+		},
+		{
+			name:     i.checksumFileName(),
+			url:      i.checksumURL(),
+			filePath: path.Join(i.dagFolderPath, checksumFile),
+		},
+	}
+	// the wallet can still be used without the SDK
+	for _, jar := range walletSDKFiles {
+		downloads = append(downloads, &download{
+			name:     jar,
+			url:      walletSDKURL + jar,
+			filePath: path.Join(i.dagFolderPath, jar),
+			optional: true,
+			cacheURL: true,
+		})
+	}
+	return downloads, nil
+}
+
+// downloadRelease downloads the package, its checksum and the wallet SDK of the release
+// concurrently, files found in the download cache are copied from there instead. Failing wallet
+// SDK downloads are reported as a warning.
+func (i *Install) downloadRelease(ctx context.Context) error {
+	err := i.resolveRelease(ctx)
+	if err != nil {
+		return err
+	}
+	downloads, err := i.releaseDownloads()
+	if err != nil {
+		return err
+	}
+	pkg, checksum := downloads[0], downloads[1]
+
+	if i.cache.enabled() {
+		// the checksum tells whether the package is cached, so it goes first
+		_, err = i.downloadAll(ctx, []*download{checksum})
+		if err != nil {
+			return err
+		}
+		pkg.sha256, err = readChecksum(checksum.filePath)
+		if err != nil {
+			return fmt.Errorf("unable to read the release checksum: %v", err)
+		}
+	}
+
+	var pending []*download
+	for _, d := range downloads {
+		if d.done {
+			continue
+		}
+		sum := d.sha256
+		if sum == "" && d.cacheURL {
+			sum = i.cache.lookup(d.url)
+		}
+		if i.cache.restore(sum, d.filePath) {
+			log.Infof("Using the cached %s", d.name)
+			d.done = true
+			continue
+		}
+		pending = append(pending, d)
+	}
+
+	optionalErrs, err := i.downloadAll(ctx, pending)
+	if err != nil {
+		return err
+	}
+	i.archivePath = pkg.filePath
+	i.checksumPath = checksum.filePath
+
+	// the package is cached once its checksum is verified
+	for _, d := range pending {
+		if d.done && d.cacheURL {
+			i.cache.store(d.url, d.filePath)
+		}
+	}
+
+	if len(optionalErrs) > 0 {
+		msgs := make([]string, len(optionalErrs))
+		for n, err := range optionalErrs {
+			msgs[n] = err.Error()
+		}
+		i.sendWarningNotification(codeWalletSDK, "Unable to download CL files", strings.Join(msgs, "; "))
+		log.Errorf("Unable to download CL files: %s", strings.Join(msgs, "; "))
+	}
+	return nil
+}
+
+// downloadAll downloads the files concurrently, at most maxParallelDownloads at a time, and
+// reports their combined progress. The first required download to fail cancels the others and
+// its error is returned. The errors of failed optional downloads are returned separately.
+func (i *Install) downloadAll(ctx context.Context, downloads []*download) ([]error, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu           sync.Mutex // guards the fields below and the progress of the downloads
+		firstErr     error
+		optionalErrs []error
+		wg           sync.WaitGroup
+	)
+	slots := make(chan struct{}, maxParallelDownloads)
+
+	stopReporting := i.reportDownloadProgress(&mu, downloads)
+	defer stopReporting()
+
+	for _, d := range downloads {
+		wg.Add(1)
+		go func(d *download) {
+			defer wg.Done()
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			case <-ctx.Done():
+				return
+			}
+
+			log.Infof("Downloading %s from %s", d.name, d.url)
+			err := downloadFile(ctx, i.client, d.url, d.filePath, func(written, size int64) {
+				mu.Lock()
+				defer mu.Unlock()
+				d.written = written
+				if size > 0 {
+					d.size = size
+				}
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				log.Infof("Downloaded %s", d.name)
+				d.done = true
+			case d.optional && ctx.Err() == nil:
+				log.Warnf("Unable to download %s: %v", d.name, err)
+				optionalErrs = append(optionalErrs, fmt.Errorf("unable to download %s: %v", d.name, err))
+			case firstErr == nil:
+				// fail fast, the siblings are only wasting bandwidth now
+				firstErr = fmt.Errorf("unable to download %s: %v", d.name, err)
+				cancel()
+			}
+		}(d)
+	}
+	wg.Wait()
+
+	if firstErr == nil && ctx.Err() != nil {
+		firstErr = ctx.Err()
+	}
+	return optionalErrs, firstErr
+}
+
+// reportDownloadProgress periodically moves the progress bar by the combined progress of the
+// downloads, until the returned function is called. mu guards the progress of the downloads.
+func (i *Install) reportDownloadProgress(mu *sync.Mutex, downloads []*download) func() {
+	stopCh := make(chan struct{})
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(downloadProgressInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stopCh:
+				return
+			case <-ticker.C:
+			}
+
+			var written, known, size int64
+			mu.Lock()
+			for _, d := range downloads {
+				written += d.written
+				if d.size > 0 {
+					known += d.written
+					size += d.size
+				}
+			}
+			mu.Unlock()
+
+			if size == 0 {
+				i.progress.status(fmt.Sprintf("Downloading packages... %s", formatBytes(written)))
+				continue
+			}
+			i.progress.fraction(float64(known)/float64(size),
+				fmt.Sprintf("Downloading packages... %s of %s", formatBytes(known), formatBytes(size)))
+		}
+	}()
+
+	return func() {
+		close(stopCh)
+		wg.Wait()
+	}
+}
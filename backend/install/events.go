@@ -0,0 +1,143 @@
+package install
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// errorCode identifies the cause of an "error" or "warning" event, so automation doesn't have
+// to match on the user facing messages
+type errorCode string
+
+const (
+	codeBusy            errorCode = "installer_busy"
+	codeCancelled       errorCode = "cancelled"
+	codeStepFailed      errorCode = "step_failed"
+	codeStopApps        errorCode = "stop_apps_failed"
+	codeWalletSDK       errorCode = "wallet_sdk_unavailable"
+	codeLaunch          errorCode = "launch_failed"
+	codeCleanUp         errorCode = "cleanup_failed"
+	codeRemoveFiles     errorCode = "remove_files_failed"
+	codeRemoveShortcuts errorCode = "remove_shortcuts_failed"
+)
+
+// Event is an event sent to the frontend in the form written to an event writer
+type Event struct {
+	Time      time.Time        `json:"time"`
+	RunID     string           `json:"run_id"`
+	Event     string           `json:"event"`
+	Step      string           `json:"step,omitempty"`
+	Code      string           `json:"code,omitempty"`
+	Title     string           `json:"title,omitempty"`
+	Message   string           `json:"message,omitempty"`
+	Progress  *int             `json:"progress,omitempty"`
+	State     *InstallState    `json:"state,omitempty"`
+	Preflight *preflightReport `json:"preflight,omitempty"`
+}
+
+// newEvent converts the arguments of a frontend event into an Event
+func newEvent(name string, data ...interface{}) *Event {
+	e := &Event{
+		Time:  time.Now().UTC(),
+		RunID: RunID(),
+		Event: name,
+		Step:  logStep(),
+	}
+
+	arg := func(n int) string {
+		if n >= len(data) {
+			return ""
+		}
+		return fmt.Sprint(data[n])
+	}
+
+	switch name {
+	case "progress":
+		if len(data) > 0 {
+			if percent, ok := data[0].(int); ok {
+				e.Progress = &percent
+			}
+		}
+	case "status":
+		e.Message = arg(0)
+	case "error", "warning", "success", "prompt":
+		e.Title = arg(0)
+		e.Message = arg(1)
+		e.Code = arg(2)
+	case "step-start", "step-end":
+		// the step is only set on the logger while it runs, take it from the event
+		e.Step = arg(0)
+		e.Message = arg(1)
+		if name == "step-end" && e.Message != "" {
+			e.Code = string(codeStepFailed)
+		}
+	case "state":
+		if len(data) > 0 {
+			if s, ok := data[0].(InstallState); ok {
+				e.State = &s
+				e.Step = s.Step
+			}
+		}
+	case "preflight":
+		if len(data) > 0 {
+			if r, ok := data[0].(*preflightReport); ok {
+				e.Preflight = r
+			}
+		}
+	default:
+		if len(data) > 0 {
+			e.Message = fmt.Sprint(data...)
+		}
+	}
+	return e
+}
+
+// eventWriter writes events to w, one per line, either as JSON objects or as plain text
+type eventWriter struct {
+	mu   sync.Mutex
+	w    io.Writer
+	json bool
+}
+
+func (ew *eventWriter) write(e *Event) {
+	ew.mu.Lock()
+	defer ew.mu.Unlock()
+
+	if ew.json {
+		json.NewEncoder(ew.w).Encode(e)
+		return
+	}
+
+	// plain text only shows what a person watching the terminal cares about
+	switch e.Event {
+	case "status":
+		fmt.Fprintln(ew.w, e.Message)
+	case "error", "warning":
+		fmt.Fprintf(ew.w, "%s: %s: %s\n", e.Event, e.Title, e.Message)
+	case "success":
+		fmt.Fprintf(ew.w, "%s %s\n", e.Title, e.Message)
+	}
+}
+
+// SetEventWriter writes every event sent to the frontend to w as well, one per line. With
+// asJSON set each line is an Event encoded as JSON, otherwise only status messages,
+// warnings, errors and successes are written as plain text. A nil w stops writing events.
+func (i *Install) SetEventWriter(w io.Writer, asJSON bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if w == nil {
+		i.events = nil
+		return
+	}
+	i.events = &eventWriter{w: w, json: asJSON}
+}
+
+func (i *Install) eventWriter() *eventWriter {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.events
+}
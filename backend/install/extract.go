@@ -0,0 +1,401 @@
+package install
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/ulikunitz/xz"
+)
+
+// archiveExtractor extracts a release package into a destination folder
+type archiveExtractor interface {
+	Extract(archive, dst string, limits extractLimits) (*extractReport, error)
+}
+
+type zipExtractor struct{}
+
+// tarExtractor extracts tar archives, decompress wraps the raw file in the matching decompressor
+type tarExtractor struct {
+	decompress func(r io.Reader) (io.Reader, error)
+}
+
+// archiveFormats maps the supported package file extensions to their extractors, in order of preference.
+// The tar formats keep Unix permissions and symlinks intact, so they are preferred when a release offers them.
+var archiveFormats = []struct {
+	ext       string
+	extractor archiveExtractor
+}{
+	{".tar.xz", tarExtractor{decompress: func(r io.Reader) (io.Reader, error) { return xz.NewReader(r) }}},
+	{".tar.gz", tarExtractor{decompress: func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }}},
+	{".tgz", tarExtractor{decompress: func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }}},
+	{".zip", zipExtractor{}},
+}
+
+// extractorFor returns the extractor matching the file extension of archive
+func extractorFor(archive string) (archiveExtractor, error) {
+	name := strings.ToLower(archive)
+	for _, format := range archiveFormats {
+		if strings.HasSuffix(name, format.ext) {
+			return format.extractor, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported archive format: %s", filepath.Base(archive))
+}
+
+// extractArchive extracts archive into dst using the extractor matching its file extension
+func extractArchive(archive, dst string, limits extractLimits) (*extractReport, error) {
+	extractor, err := extractorFor(archive)
+	if err != nil {
+		return nil, err
+	}
+	return extractor.Extract(archive, dst, limits)
+}
+
+// extractLimits protects against archive bombs
+type extractLimits struct {
+	maxTotalSize int64 // total uncompressed size of all entries
+	maxEntries   int
+}
+
+var defaultExtractLimits = extractLimits{
+	maxTotalSize: 2 << 30, // 2 GiB
+	maxEntries:   10000,
+}
+
+// extractReport lists what was extracted from an archive, paths are relative to the destination
+type extractReport struct {
+	files    []string
+	dirs     []string
+	symlinks []string
+	size     int64
+}
+
+func (r *extractReport) String() string {
+	return fmt.Sprintf("%d files, %d directories, %d symlinks, %d bytes", len(r.files), len(r.dirs), len(r.symlinks), r.size)
+}
+
+// archiveWriter writes archive entries below dst, refusing anything that would end up
+// outside of it or exceed the limits.
+type archiveWriter struct {
+	dst     string
+	limits  extractLimits
+	entries int
+	report  *extractReport
+}
+
+func newArchiveWriter(dst string, limits extractLimits) (*archiveWriter, error) {
+	dst, err := filepath.Abs(dst)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return nil, err
+	}
+	// resolve symlinks in dst itself (e.g. /tmp on MacOS) so containment checks compare like with like
+	dst, err = filepath.EvalSymlinks(dst)
+	if err != nil {
+		return nil, err
+	}
+	return &archiveWriter{dst: dst, limits: limits, report: &extractReport{}}, nil
+}
+
+// resolve returns the absolute path for the archive entry name, rejecting absolute
+// paths, ../ traversal and paths that lead through a symlink out of dst.
+func (w *archiveWriter) resolve(name string) (string, error) {
+	w.entries++
+	if w.limits.maxEntries > 0 && w.entries > w.limits.maxEntries {
+		return "", fmt.Errorf("archive contains more than %d entries", w.limits.maxEntries)
+	}
+
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if filepath.IsAbs(clean) || filepath.VolumeName(clean) != "" || strings.HasPrefix(name, "/") {
+		return "", fmt.Errorf("illegal absolute path in archive: %s", name)
+	}
+	if clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("illegal path traversal in archive: %s", name)
+	}
+
+	target := filepath.Join(w.dst, clean)
+
+	// a previously extracted symlink must not redirect this entry out of dst
+	parent, err := filepath.EvalSymlinks(filepath.Dir(target))
+	if err == nil && !w.inside(parent) {
+		return "", fmt.Errorf("archive entry %s escapes the destination through a symlink", name)
+	}
+
+	return target, nil
+}
+
+func (w *archiveWriter) inside(p string) bool {
+	rel, err := filepath.Rel(w.dst, p)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
+func (w *archiveWriter) rel(p string) string {
+	rel, err := filepath.Rel(w.dst, p)
+	if err != nil {
+		return p
+	}
+	return filepath.ToSlash(rel)
+}
+
+func (w *archiveWriter) mkdir(name string, mode os.FileMode) error {
+	target, err := w.resolve(name)
+	if err != nil {
+		return err
+	}
+	if mode.Perm() == 0 {
+		mode = 0755
+	}
+	if err := os.MkdirAll(target, mode.Perm()|0700); err != nil {
+		return err
+	}
+	w.report.dirs = append(w.report.dirs, w.rel(target))
+	return nil
+}
+
+func (w *archiveWriter) writeFile(name string, mode os.FileMode, r io.Reader) error {
+	target, err := w.resolve(name)
+	if err != nil {
+		return err
+	}
+	// archives created on Windows carry no unix permissions
+	if mode.Perm() == 0 {
+		mode = dataFileMode
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	// don't write through a symlink extracted earlier
+	if info, err := os.Lstat(target); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		os.Remove(target)
+	}
+
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// never trust the sizes declared in the archive headers, count what is actually written
+	if w.limits.maxTotalSize > 0 {
+		r = io.LimitReader(r, w.limits.maxTotalSize-w.report.size+1)
+	}
+	n, err := io.Copy(f, r)
+	w.report.size += n
+	if err != nil {
+		return err
+	}
+	if w.limits.maxTotalSize > 0 && w.report.size > w.limits.maxTotalSize {
+		return fmt.Errorf("archive exceeds the maximum extracted size of %d bytes", w.limits.maxTotalSize)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	// the umask may have stripped bits from the mode passed to OpenFile
+	if err := os.Chmod(target, mode.Perm()); err != nil {
+		return err
+	}
+
+	w.report.files = append(w.report.files, w.rel(target))
+	return nil
+}
+
+func (w *archiveWriter) symlink(name, linkTarget string) error {
+	target, err := w.resolve(name)
+	if err != nil {
+		return err
+	}
+	if filepath.IsAbs(linkTarget) || strings.HasPrefix(linkTarget, "/") {
+		return fmt.Errorf("illegal absolute symlink in archive: %s -> %s", name, linkTarget)
+	}
+	if !w.inside(filepath.Join(filepath.Dir(target), filepath.FromSlash(linkTarget))) {
+		return fmt.Errorf("symlink in archive escapes the destination: %s -> %s", name, linkTarget)
+	}
+
+	if runtime.GOOS == "windows" {
+		// symlinks are only used in MacOS app bundles and need elevated privileges on Windows
+		log.Warnf("Skipping symlink %s -> %s on Windows", name, linkTarget)
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	os.Remove(target)
+	if err := os.Symlink(linkTarget, target); err != nil {
+		return err
+	}
+	w.report.symlinks = append(w.report.symlinks, w.rel(target))
+	return nil
+}
+
+// verifySymlinks makes sure no extracted symlink resolves outside of dst. Links are
+// checked lexically while extracting, this catches chains of links that only escape
+// once they're all in place.
+func (w *archiveWriter) verifySymlinks() error {
+	for _, link := range w.report.symlinks {
+		resolved, err := filepath.EvalSymlinks(filepath.Join(w.dst, filepath.FromSlash(link)))
+		if err != nil {
+			// dangling links can't be followed out of dst
+			continue
+		}
+		if !w.inside(resolved) {
+			os.Remove(filepath.Join(w.dst, filepath.FromSlash(link)))
+			return fmt.Errorf("symlink in archive escapes the destination: %s", link)
+		}
+	}
+	return nil
+}
+
+// Extract extracts a zip archive into dst
+func (zipExtractor) Extract(archive, dst string, limits extractLimits) (*extractReport, error) {
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open zip archive: %v", err)
+	}
+	defer r.Close()
+
+	w, err := newArchiveWriter(dst, limits)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range r.File {
+		err := extractZipEntry(w, f)
+		if err != nil {
+			return w.report, err
+		}
+	}
+	return w.report, w.verifySymlinks()
+}
+
+func extractZipEntry(w *archiveWriter, f *zip.File) error {
+	mode := f.Mode()
+
+	switch {
+	case mode.IsDir():
+		return w.mkdir(f.Name, mode)
+
+	case mode&os.ModeSymlink != 0:
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		linkTarget, err := readSymlinkTarget(rc)
+		if err != nil {
+			return err
+		}
+		return w.symlink(f.Name, linkTarget)
+
+	case mode.IsRegular():
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		return w.writeFile(f.Name, mode, rc)
+
+	default:
+		return fmt.Errorf("unsupported file type in archive: %s (%v)", f.Name, mode)
+	}
+}
+
+// readSymlinkTarget reads the link target stored as the content of a symlink entry
+func readSymlinkTarget(r io.Reader) (string, error) {
+	const maxLen = 4096
+	b, err := ioutil.ReadAll(io.LimitReader(r, maxLen+1))
+	if err != nil {
+		return "", err
+	}
+	if len(b) > maxLen {
+		return "", fmt.Errorf("symlink target too long")
+	}
+	return string(b), nil
+}
+
+// Extract extracts a (compressed) tar archive into dst
+func (t tarExtractor) Extract(archive, dst string, limits extractLimits) (*extractReport, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open tar archive: %v", err)
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if t.decompress != nil {
+		r, err = t.decompress(f)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decompress archive: %v", err)
+		}
+	}
+
+	w, err := newArchiveWriter(dst, limits)
+	if err != nil {
+		return nil, err
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return w.report, fmt.Errorf("unable to read tar archive: %v", err)
+		}
+
+		err = extractTarEntry(w, hdr, tr)
+		if err != nil {
+			return w.report, err
+		}
+	}
+	return w.report, w.verifySymlinks()
+}
+
+func extractTarEntry(w *archiveWriter, hdr *tar.Header, r io.Reader) error {
+	mode := os.FileMode(hdr.Mode).Perm()
+
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		return w.mkdir(hdr.Name, mode)
+
+	case tar.TypeReg, tar.TypeRegA:
+		return w.writeFile(hdr.Name, mode, r)
+
+	case tar.TypeSymlink:
+		return w.symlink(hdr.Name, hdr.Linkname)
+
+	case tar.TypeLink:
+		// hard links point to an entry extracted earlier, copy it
+		src, err := w.resolve(hdr.Linkname)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(src)
+		if err != nil {
+			return fmt.Errorf("unable to resolve hard link %s -> %s: %v", hdr.Name, hdr.Linkname, err)
+		}
+		defer f.Close()
+		return w.writeFile(hdr.Name, mode, f)
+
+	case tar.TypeXGlobalHeader:
+		return nil
+
+	default:
+		return fmt.Errorf("unsupported file type in archive: %s (%c)", hdr.Name, hdr.Typeflag)
+	}
+}
@@ -0,0 +1,246 @@
+package install
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/ulikunitz/xz"
+)
+
+// archiveEntry is an entry of an archive built by a test
+type archiveEntry struct {
+	name string
+	body string
+	mode os.FileMode // the permissions, or'ed with os.ModeDir or os.ModeSymlink
+	link string      // the target of a symlink
+}
+
+func file(name, body string, mode os.FileMode) archiveEntry {
+	return archiveEntry{name: name, body: body, mode: mode}
+}
+
+func dir(name string) archiveEntry {
+	return archiveEntry{name: name, mode: os.ModeDir | 0755}
+}
+
+func symlink(name, target string) archiveEntry {
+	return archiveEntry{name: name, mode: os.ModeSymlink | 0777, link: target}
+}
+
+func buildZip(t *testing.T, entries []archiveEntry) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		if e.mode&os.ModeDir != 0 {
+			hdr.Name += "/"
+		}
+		hdr.SetMode(e.mode)
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.mode&os.ModeSymlink != 0 {
+			w.Write([]byte(e.link))
+		} else {
+			w.Write([]byte(e.body))
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func buildTar(t *testing.T, entries []archiveEntry) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: int64(e.mode.Perm()), Size: int64(len(e.body)), Typeflag: tar.TypeReg}
+		switch {
+		case e.mode&os.ModeDir != 0:
+			hdr.Typeflag, hdr.Name, hdr.Size = tar.TypeDir, e.name+"/", 0
+		case e.mode&os.ModeSymlink != 0:
+			hdr.Typeflag, hdr.Linkname, hdr.Size = tar.TypeSymlink, e.link, 0
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			tw.Write([]byte(e.body))
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// archiveBuilders build an archive of every supported format, keyed by file extension
+var archiveBuilders = map[string]func(t *testing.T, entries []archiveEntry) []byte{
+	".zip": buildZip,
+	".tar.gz": func(t *testing.T, entries []archiveEntry) []byte {
+		var buf bytes.Buffer
+		gw := gzip.NewWriter(&buf)
+		gw.Write(buildTar(t, entries))
+		if err := gw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	},
+	".tar.xz": func(t *testing.T, entries []archiveEntry) []byte {
+		var buf bytes.Buffer
+		xw, err := xz.NewWriter(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		xw.Write(buildTar(t, entries))
+		if err := xw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	},
+}
+
+func TestExtractArchive(t *testing.T) {
+	limits := extractLimits{maxTotalSize: 64, maxEntries: 4}
+
+	tests := []struct {
+		name     string
+		entries  []archiveEntry
+		symlinks bool   // the archive has symlinks, which aren't extracted on windows
+		err      string // part of the expected error, empty if the archive is valid
+	}{
+		{
+			name:    "files and folders",
+			entries: []archiveEntry{dir("bin"), file("bin/mollywallet", "wallet", 0755), file("readme.txt", "read me", 0644)},
+		},
+		{
+			name:    "relative traversal",
+			entries: []archiveEntry{file("../evil", "evil", 0644)},
+			err:     "illegal path traversal",
+		},
+		{
+			name:    "traversal through a folder",
+			entries: []archiveEntry{file("bin/../../evil", "evil", 0644)},
+			err:     "illegal path traversal",
+		},
+		{
+			name:    "absolute path",
+			entries: []archiveEntry{file("/tmp/evil", "evil", 0644)},
+			err:     "illegal absolute path",
+		},
+		{
+			name:     "symlink inside",
+			entries:  []archiveEntry{file("lib/real", "real", 0644), symlink("lib/current", "real")},
+			symlinks: true,
+		},
+		{
+			name:     "symlink escaping",
+			entries:  []archiveEntry{symlink("up", "../outside")},
+			symlinks: true,
+			err:      "escapes the destination",
+		},
+		{
+			name:     "absolute symlink",
+			entries:  []archiveEntry{symlink("passwd", "/etc/passwd")},
+			symlinks: true,
+			err:      "illegal absolute symlink",
+		},
+		{
+			name:     "chained symlinks escaping",
+			entries:  []archiveEntry{dir("sub"), symlink("sub/up", ".."), symlink("sub/out", "up/..")},
+			symlinks: true,
+			err:      "escapes the destination",
+		},
+		{
+			name:    "too many entries",
+			entries: []archiveEntry{file("1", "", 0644), file("2", "", 0644), file("3", "", 0644), file("4", "", 0644), file("5", "", 0644)},
+			err:     "more than 4 entries",
+		},
+		{
+			name:    "too large",
+			entries: []archiveEntry{file("big", strings.Repeat("x", 65), 0644)},
+			err:     "maximum extracted size",
+		},
+	}
+
+	for ext, build := range archiveBuilders {
+		for _, tt := range tests {
+			t.Run(ext+"/"+tt.name, func(t *testing.T) {
+				if tt.symlinks && runtime.GOOS == "windows" {
+					t.Skip("symlinks are skipped on windows")
+				}
+				root := t.TempDir()
+				archive := filepath.Join(root, "package"+ext)
+				writeFile(t, archive, string(build(t, tt.entries)))
+				dst := filepath.Join(root, "dst")
+
+				report, err := extractArchive(archive, dst, limits)
+				if tt.err != "" {
+					if err == nil || !strings.Contains(err.Error(), tt.err) {
+						t.Fatalf("err = %v, want %q", err, tt.err)
+					}
+					if fileExists(filepath.Join(root, "evil")) || fileExists(filepath.Join(root, "outside")) {
+						t.Error("an entry was written outside of the destination")
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				for _, e := range tt.entries {
+					if e.mode&(os.ModeDir|os.ModeSymlink) != 0 {
+						continue
+					}
+					data, err := ioutil.ReadFile(filepath.Join(dst, e.name))
+					if err != nil || string(data) != e.body {
+						t.Errorf("%s = %q, %v, want %q", e.name, data, err, e.body)
+					}
+				}
+				if len(report.files) == 0 {
+					t.Errorf("empty report %v", report)
+				}
+			})
+		}
+	}
+}
+
+func TestExtractArchiveKeepsExecutableBit(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("windows has no executable bit")
+	}
+	entries := []archiveEntry{file("mollywallet", "wallet", 0755), file("data.json", "{}", 0600), file("from-windows.txt", "", 0)}
+	want := map[string]os.FileMode{"mollywallet": 0755, "data.json": 0600, "from-windows.txt": dataFileMode}
+
+	for ext, build := range archiveBuilders {
+		t.Run(ext, func(t *testing.T) {
+			root := t.TempDir()
+			archive := filepath.Join(root, "package"+ext)
+			writeFile(t, archive, string(build(t, entries)))
+
+			_, err := extractArchive(archive, filepath.Join(root, "dst"), defaultExtractLimits)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, mode := range want {
+				info, err := os.Stat(filepath.Join(root, "dst", name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if info.Mode().Perm() != mode {
+					t.Errorf("%s has mode %v, want %v", name, info.Mode().Perm(), mode)
+				}
+			}
+		})
+	}
+}
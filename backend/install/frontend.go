@@ -1,57 +1,63 @@
 package install
 
 import (
-	"time"
-
 	log "github.com/sirupsen/logrus"
 	"github.com/wailsapp/wails"
 )
 
-func (i *Install) startProgress() {
-
-	var percent int
-
-	go func() {
-		for percent < 97 {
-			percent++
-			time.Sleep(2000 * time.Millisecond)
-			i.incrementProgress(percent)
-		}
-	}()
+// updateProgress moves the progress bar to progress, letting it creep towards ceiling
+// until the next update, and shows progressMsg as status
+func (i *Install) updateProgress(progress, ceiling int, progressMsg string) {
+	i.progress.update(progress, ceiling, progressMsg)
+	log.Infoln(progressMsg)
+}
 
-	for {
+// updateStatus changes the status message without moving the progress bar
+func (i *Install) updateStatus(msg string) {
+	i.progress.status(msg)
+	log.Infoln(msg)
+}
 
-		select {
-		case percent = <-i.incrementProgressCh:
-			i.incrementProgress(percent)
-		case msg := <-i.progressMessageCh:
-			i.sendStatusMsg(msg)
-		}
+// emit sends an event to the frontend, if there is one, and to the event writer, if one is set
+func (i *Install) emit(event string, data ...interface{}) {
+	if ew := i.eventWriter(); ew != nil {
+		ew.write(newEvent(event, data...))
 	}
+	if i.frontend == nil {
+		return
+	}
+	i.frontend.Events.Emit(event, data...)
 }
 
-func (i *Install) updateProgress(progress int, progressMsg string) {
-	i.incrementProgressCh <- progress
-	i.progressMessageCh <- progressMsg
-	log.Infoln(progressMsg)
+// sendErrorNotification reports a failure that stops the current operation
+func (i *Install) sendErrorNotification(code errorCode, title, msg string) {
+	i.emit("error", title, msg, string(code))
 }
 
-func (i *Install) sendStatusMsg(msg string) {
-	i.frontend.Events.Emit("status", msg)
-	return
+// sendWarningNotification reports a failure the current operation recovers from
+func (i *Install) sendWarningNotification(code errorCode, title, msg string) {
+	i.mu.Lock()
+	i.warnings = append(i.warnings, warning{code: code, title: title, msg: msg})
+	i.mu.Unlock()
+	i.emit("warning", title, msg, string(code))
 }
 
-func (i *Install) incrementProgress(percent int) {
-	i.frontend.Events.Emit("progress", percent)
-	return
+// warning is a failure an operation recovered from
+type warning struct {
+	code  errorCode
+	title string
+	msg   string
 }
 
-func (i *Install) sendErrorNotification(title, msg string) {
-	i.frontend.Events.Emit("error", title, msg)
+// runWarnings returns the warnings of the last installation
+func (i *Install) runWarnings() []warning {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return append([]warning(nil), i.warnings...)
 }
 
 func (i *Install) sendSuccessNotification(title, msg string) {
-	i.frontend.Events.Emit("success", title, msg)
+	i.emit("success", title, msg)
 }
 
 // WailsInit will be called automatically when the binary runs.
@@ -0,0 +1,314 @@
+package install
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// maxRateLimitWait is the longest the client waits for a rate limit to reset before giving up
+	maxRateLimitWait = time.Minute
+
+	// maxAPIResponseSize bounds the size of a GitHub API response read into memory
+	maxAPIResponseSize = 8 << 20
+)
+
+// the API is cheap to query, but GitHub has the occasional hiccup
+var apiRetryPolicy = retryPolicy{attempts: 3, delay: time.Second, maxDelay: 10 * time.Second, multiplier: 2, jitter: 0.2}
+
+// githubClient queries the GitHub releases API of the wallet repo. Responses are cached on disk
+// with their ETag, so repeated lookups are answered with 304 Not Modified, which GitHub doesn't
+// count against the rate limit.
+type githubClient struct {
+	baseURL  string
+	token    string // optional, raises the rate limit from 60 to 5000 requests per hour
+	cacheDir string // empty disables the cache
+	client   *http.Client
+}
+
+func newGithubClient(client *http.Client, token, cacheDir string) *githubClient {
+	return &githubClient{
+		baseURL:  releasesAPIURL,
+		token:    token,
+		cacheDir: cacheDir,
+		client:   client,
+	}
+}
+
+// rateLimitError is returned when the GitHub API rate limit is exhausted
+type rateLimitError struct {
+	reset         time.Time // when the limit resets, zero if unknown
+	authenticated bool
+}
+
+func (e *rateLimitError) Error() string {
+	msg := "GitHub API rate limit exceeded"
+	if !e.reset.IsZero() {
+		msg += fmt.Sprintf(", it resets at %s", e.reset.Local().Format("15:04:05"))
+	}
+	if !e.authenticated {
+		msg += ". Set GITHUB_TOKEN to raise the limit"
+	}
+	return msg
+}
+
+// releaseNotFoundError is returned when the requested release doesn't exist
+type releaseNotFoundError struct {
+	tag     string  // empty for the latest release
+	channel Channel // set if no release was found on the channel
+}
+
+func (e *releaseNotFoundError) Error() string {
+	switch {
+	case e.tag != "":
+		return fmt.Sprintf("release %s not found", e.tag)
+	case e.channel != "":
+		return fmt.Sprintf("no Molly Wallet release has been published on the %s channel", e.channel)
+	}
+	return "no Molly Wallet release has been published"
+}
+
+// apiError is returned for any other unexpected response of the GitHub API
+type apiError struct {
+	status  string
+	message string // the message in the body of the response, if any
+}
+
+func (e *apiError) Error() string {
+	if e.message == "" {
+		return fmt.Sprintf("unexpected response from GitHub API: %s", e.status)
+	}
+	return fmt.Sprintf("unexpected response from GitHub API: %s: %s", e.status, e.message)
+}
+
+// latestRelease returns the latest published release
+func (c *githubClient) latestRelease(ctx context.Context) (*release, error) {
+	r := &release{}
+	err := c.get(ctx, c.baseURL+"/latest", r)
+	if err != nil {
+		return nil, err
+	}
+	if r.TagName == "" {
+		return nil, fmt.Errorf("the latest release has no tag")
+	}
+	return r, nil
+}
+
+// releaseByTag returns the release tagged tag
+func (c *githubClient) releaseByTag(ctx context.Context, tag string) (*release, error) {
+	r := &release{}
+	err := c.get(ctx, c.baseURL+"/tags/"+tag, r)
+	if _, ok := err.(*releaseNotFoundError); ok {
+		return nil, &releaseNotFoundError{tag: tag}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// get fetches url and decodes the JSON response into v. Transient failures are retried, typed
+// errors are returned unwrapped.
+func (c *githubClient) get(ctx context.Context, url string, v interface{}) error {
+	var body []byte
+	err := apiRetryPolicy.retry(ctx, "querying "+url, func() error {
+		var err error
+		body, err = c.fetch(ctx, url)
+		return err
+	})
+	if err != nil {
+		return unwrapAPIError(err)
+	}
+
+	err = json.Unmarshal(body, v)
+	if err != nil {
+		return fmt.Errorf("unable to parse GitHub API response: %v", err)
+	}
+	return nil
+}
+
+// unwrapAPIError returns the typed error behind the retry and permanent wrappers, if any
+func unwrapAPIError(err error) error {
+	var rerr *rateLimitError
+	var nerr *releaseNotFoundError
+	var aerr *apiError
+	switch {
+	case errors.As(err, &rerr):
+		return rerr
+	case errors.As(err, &nerr):
+		return nerr
+	case errors.As(err, &aerr):
+		return aerr
+	}
+	return err
+}
+
+// fetch does a single, conditional request for url
+func (c *githubClient) fetch(ctx context.Context, url string) ([]byte, error) {
+	cached := c.cached(url)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, permanent(err)
+	}
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "token "+c.token)
+	}
+	if cached != nil && cached.ETag != "" {
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send HTTP request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxAPIResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read GitHub API response: %v", err)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		log.Debugf("GitHub API response for %s not modified, using the cached copy", url)
+		return cached.Body, nil
+	case resp.StatusCode == http.StatusOK:
+		c.store(url, resp.Header.Get("ETag"), body)
+		return body, nil
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, permanent(&releaseNotFoundError{})
+	}
+
+	if wait, limited := c.rateLimited(resp); limited {
+		if cached != nil {
+			log.Warnf("GitHub API rate limit exceeded, using the cached response for %s", url)
+			return cached.Body, nil
+		}
+		rerr := &rateLimitError{authenticated: c.token != ""}
+		if wait > 0 {
+			rerr.reset = time.Now().Add(wait)
+		}
+		if wait > maxRateLimitWait {
+			return nil, permanent(rerr)
+		}
+		log.Warnf("GitHub API rate limit exceeded, waiting %s", wait)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, permanent(ctx.Err())
+		}
+		return nil, rerr
+	}
+
+	aerr := &apiError{status: resp.Status}
+	var msg struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &msg) == nil {
+		aerr.message = msg.Message
+	}
+	if resp.StatusCode >= 500 {
+		return nil, aerr
+	}
+	return nil, permanent(aerr)
+}
+
+// rateLimited reports whether resp was rejected by a rate limit and how long to wait before
+// trying again, zero if unknown
+func (c *githubClient) rateLimited(resp *http.Response) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+
+	// secondary rate limits tell how long to back off
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		return 0, resp.StatusCode == http.StatusTooManyRequests
+	}
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return 0, true
+	}
+	wait := time.Until(time.Unix(reset, 0))
+	if wait < 0 {
+		wait = 0
+	}
+	return wait, true
+}
+
+// cachedResponse is a GitHub API response stored on disk
+type cachedResponse struct {
+	URL  string          `json:"url"`
+	ETag string          `json:"etag"`
+	Body json.RawMessage `json:"body"`
+}
+
+func (c *githubClient) cachePath(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(c.cacheDir, hex.EncodeToString(sum[:8])+".json")
+}
+
+// cached returns the cached response for url, or nil if there is none
+func (c *githubClient) cached(url string) *cachedResponse {
+	if c.cacheDir == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(c.cachePath(url))
+	if err != nil {
+		return nil
+	}
+	cr := &cachedResponse{}
+	if json.Unmarshal(data, cr) != nil || cr.URL != url {
+		return nil
+	}
+	return cr
+}
+
+// store caches the response for url. Failures only cost a request next time, so they're logged.
+func (c *githubClient) store(url, etag string, body []byte) {
+	if c.cacheDir == "" || etag == "" || !json.Valid(body) {
+		return
+	}
+	data, err := json.Marshal(&cachedResponse{URL: url, ETag: etag, Body: body})
+	if err != nil {
+		return
+	}
+
+	err = os.MkdirAll(c.cacheDir, 0700)
+	if err == nil {
+		tmp := c.cachePath(url) + ".tmp"
+		err = ioutil.WriteFile(tmp, data, 0600)
+		if err == nil {
+			err = os.Rename(tmp, c.cachePath(url))
+		}
+	}
+	if err != nil {
+		log.Warnf("Unable to cache the GitHub API response for %s: %v", url, err)
+	}
+}
+
+// githubCacheDir returns the folder the GitHub API responses are cached in, empty if cacheDir is
+func githubCacheDir(cacheDir string) string {
+	if cacheDir == "" {
+		return ""
+	}
+	return filepath.Join(cacheDir, "github")
+}
@@ -0,0 +1,96 @@
+package install
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"runtime"
+	"time"
+)
+
+// Version is the version of the installer. Release builds set it with
+// -ldflags "-X github.com/grvlle/molly_installer/backend/install.Version=<version>"
+var Version = "0.1.0"
+
+// userAgent identifies the installer in every request, GitHub rejects requests without one
+func userAgent() string {
+	return fmt.Sprintf("molly-installer/%s (%s/%s)", Version, runtime.GOOS, runtime.GOARCH)
+}
+
+// tlsVersions maps the accepted values of Config.MinTLSVersion to their TLS version
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// newHTTPClient returns the client shared by all requests of the installer, configured with the
+// proxy, CA bundle and minimum TLS version of cfg
+func newHTTPClient(cfg Config) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// downloads may take long, but a server that doesn't answer at all shouldn't hang the installer
+	transport.ResponseHeaderTimeout = time.Minute
+
+	if cfg.ProxyURL != "" {
+		proxy, err := url.Parse(cfg.ProxyURL)
+		if err != nil || proxy.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", cfg.ProxyURL)
+		}
+		if cfg.ProxyUsername != "" {
+			proxy.User = url.UserPassword(cfg.ProxyUsername, cfg.ProxyPassword)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	minVersion, ok := tlsVersions[cfg.MinTLSVersion]
+	if !ok {
+		return nil, fmt.Errorf("unsupported minimum TLS version %q, expected 1.2 or 1.3", cfg.MinTLSVersion)
+	}
+	transport.TLSClientConfig = &tls.Config{MinVersion: minVersion}
+
+	if cfg.CABundle != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			// not available on windows before go 1.18, the bundle has to be complete then
+			pool = x509.NewCertPool()
+		}
+		pem, err := ioutil.ReadFile(cfg.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA bundle: %v", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", cfg.CABundle)
+		}
+		transport.TLSClientConfig.RootCAs = pool
+	}
+
+	return &http.Client{Transport: &userAgentTransport{base: transport}}, nil
+}
+
+// userAgentTransport sets the installer user agent on requests that don't have one
+type userAgentTransport struct {
+	base http.RoundTripper
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", userAgent())
+	}
+	return t.base.RoundTrip(req)
+}
+
+// proxyDescription describes the configured proxy without its credentials
+func (c Config) proxyDescription() string {
+	if c.ProxyURL == "" {
+		return "from environment"
+	}
+	proxy, err := url.Parse(c.ProxyURL)
+	if err != nil {
+		return "invalid"
+	}
+	proxy.User = nil
+	return proxy.String()
+}
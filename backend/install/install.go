@@ -1,17 +1,17 @@
 package install
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
-	"errors"
+	"context"
 	"fmt"
-	"io"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"os/exec"
 	"path"
+	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/otiai10/copy"
@@ -21,17 +21,39 @@ import (
 
 // Install type contains the Install processes mandatory data
 type Install struct {
-	downloadURL         string
-	dagFolderPath       string
-	tmpFolderPath       string
-	incrementProgressCh chan int
-	progressMessageCh   chan string
-	OSSpecificSettings  *settings
-	frontend            *wails.Runtime
+	downloadURL        string
+	version            string
+	release            *release     // metadata of the release to install, nil if it couldn't be fetched
+	build              archFallback // the build of the package picked for this platform
+	archivePath        string
+	checksumPath       string // the checksum downloaded along with the package
+	contents           *packageContents
+	backups            map[string]string
+	previous           *previousInstall // the previous installation, moved aside by PrepareFS
+	config             Config
+	client             *http.Client // shared by every request, see newHTTPClient
+	github             *githubClient
+	cache              *downloadCache
+	channel            Channel
+	options            installOptions
+	warnings           []warning // the warnings of the current or last installation
+	dagFolderPath      string    // the install folder
+	tmpFolderPath      string    // the private temp folder of the current run, empty between runs
+	progress           *progressEngine
+	promptCh           chan bool
+	closeRunningApps   bool
+	mu                 sync.Mutex
+	busy               string // the operation in progress, empty when idle
+	cancel             context.CancelFunc
+	state              InstallState
+	events             *eventWriter
+	OSSpecificSettings *settings
+	frontend           *wails.Runtime
 }
 
 type settings struct {
 	osBuild       string
+	arch          string
 	fileExt       string
 	binaryPath    string
 	startMenuPath string
@@ -39,318 +61,549 @@ type settings struct {
 	shortcutPath  string
 }
 
-type unzippedContents struct {
+type packageContents struct {
 	mollyBinaryPath  string
 	updateBinaryPath string
 	mollyMacOSApp    string
+	report           *extractReport
 }
 
-// Init initializes the Install struct
-func Init() (*Install, error) {
+// Init initializes the Install struct with the locations in cfg
+func Init(cfg Config) (*Install, error) {
 
-	userHomeDir, err := os.UserHomeDir()
+	cfg, err := cfg.withDefaults()
 	if err != nil {
-		return nil, fmt.Errorf("unable to locate users home directory: %v", err)
+		return nil, err
+	}
+	client, err := newHTTPClient(cfg)
+	if err != nil {
+		return nil, err
 	}
 
 	i := &Install{
-		downloadURL:         "https://github.com/grvlle/constellation_wallet/releases/download",
-		dagFolderPath:       path.Join(userHomeDir, ".dag"),
-		tmpFolderPath:       path.Join(userHomeDir, ".tmp"),
-		incrementProgressCh: make(chan int),
-		progressMessageCh:   make(chan string),
-		OSSpecificSettings:  getOSSpecificSettings(),
-	}
+		downloadURL:        "https://github.com/grvlle/constellation_wallet/releases/download",
+		config:             cfg,
+		client:             client,
+		github:             newGithubClient(client, cfg.GitHubToken, githubCacheDir(cfg.CacheDir)),
+		cache:              newDownloadCache(downloadCacheDir(cfg.CacheDir), cfg.CacheLimit),
+		dagFolderPath:      cfg.InstallDir,
+		promptCh:           make(chan bool),
+		state:              InstallState{State: StateIdle, UpdatedAt: time.Now()},
+		options:            defaultInstallOptions(),
+		OSSpecificSettings: getOSSpecificSettings(cfg.InstallDir),
+	}
+	i.progress = newProgressEngine(i.emit, 2*time.Second)
+	i.channel = i.loadPreferences().Channel
 	return i, err
 }
 
-// Run is the main method that runs the full install.
-func (i *Install) Run() {
-	var err error
+// Run is the main method that runs the full install. The install stops and is rolled back
+// once ctx is cancelled or Cancel is called.
+func (i *Install) Run(ctx context.Context) {
 
-	go i.startProgress() // Runs a go routine that increments the progress bar
+	err := i.begin("installation")
+	if err != nil {
+		log.Warnln(err)
+		i.sendErrorNotification(codeBusy, "Installer busy", fmt.Sprintf("%v", err))
+		return
+	}
+	defer i.end()
+	// the release is kept from a preceding GetReleaseInfo, so the confirmed release is installed,
+	// later runs look up the latest one again
+	defer i.resetRelease()
 
-	// Install Java on Windows if not detected
-	i.updateProgress(8, "Checking Java Installation...")
-	if runtime.GOOS == "windows" && !javaInstalled() {
-		i.updateProgress(10, "Java not found. Installing Java (This may take some time)...")
-		err = installJava()
+	lock, err := acquireLock(i.dagFolderPath)
+	if err != nil {
+		log.Errorf("Unable to lock the installation folder: %v", err)
+		i.setState(StateFailed, "", err)
+		i.sendErrorNotification(codeBusy, "Installer busy", fmt.Sprintf("%v", err))
+		return
+	}
+	defer lock.release()
+
+	ctx, cancel := context.WithCancel(ctx)
+	i.setCancel(cancel)
+	defer i.setCancel(nil)
+	defer cancel()
+
+	i.backups = nil
+	i.previous = nil
+	i.mu.Lock()
+	i.warnings = nil
+	i.mu.Unlock()
+
+	i.progress.start()            // Slowly increments the progress bar between steps
+	defer i.progress.stop(-1, "") // Stops incrementing, whatever the outcome
+
+	err = i.runPipeline(ctx, i.installSteps())
+	if err != nil && ctx.Err() != nil {
+		log.Warnf("Installation cancelled: %v", err)
+		msg := "The installation was cancelled and rolled back."
+		if serr, ok := err.(*stepError); ok && !serr.rolledBack {
+			msg = "The installation was cancelled, but it could not be rolled back completely. See the install log for details."
+		}
+		err = i.CleanUp()
 		if err != nil {
-			i.sendErrorNotification("Unable to install Java", fmt.Sprintf("%v", err))
-			time.Sleep(10 * time.Second)
-			log.Fatal("Unable to install Java: %v", err)
+			log.Errorf("Unable to clean up after cancelling: %v", err)
 		}
+		i.progress.stop(0, "Installation cancelled")
+		i.sendErrorNotification(codeCancelled, "Installation cancelled", msg)
+		i.emit("cancelled")
+		return
 	}
-
-	// Remove old Molly Wallet artifacts
-	i.updateProgress(33, "Preparing filesystem...")
-	err = i.PrepareFS()
 	if err != nil {
-		i.sendErrorNotification("Unable to prepare filesystem", fmt.Sprintf("%v", err))
-		time.Sleep(10 * time.Second)
-		log.Fatalf("Unable to prepare filesystem: %v", err)
+		title := "Installation failed"
+		rolledBack := false
+		if serr, ok := err.(*stepError); ok {
+			title = serr.title()
+			rolledBack = serr.rolledBack
+			setLogStep(serr.step.Name()) // tag the failure with the step that caused it
+		}
+		// the temp folder holds the backups, it's kept if they couldn't all be restored
+		if rolledBack {
+			if cerr := i.CleanUp(); cerr != nil {
+				log.Errorf("Unable to clean up after the failed installation: %v", cerr)
+			}
+		} else {
+			log.Warnf("Keeping %s, the installation could not be rolled back completely", i.tmpFolderPath)
+		}
+		percent, _ := i.progress.snapshot()
+		i.progress.stop(percent, title)
+		i.sendErrorNotification(codeStepFailed, title, fmt.Sprintf("%v", err))
+		if i.frontend == nil {
+			// headless callers read the outcome from the state
+			log.Errorf("%s: %v", title, err)
+			return
+		}
+		time.Sleep(10 * time.Second) // leave the notification up before exiting
+		log.Fatalf("%s: %v", title, err)
 	}
 
-	// Download the mollywallet.zip from https://github.com/grvlle/constellation_wallet/
-	i.updateProgress(35, "Downloading packages...")
-	zippedArchive, err := i.DownloadAppBinary()
-	if err != nil {
-		i.sendErrorNotification("Unable to download Molly Wallet package", fmt.Sprintf("%v", err))
-		time.Sleep(10 * time.Second)
-		log.Fatalf("Unable to download Molly Wallet package: %v", err)
+	i.progress.stop(100, "Installation Complete!")
+	i.setState(StateDone, "", nil)
+	i.sendSuccessNotification("Success!", "Molly wallet has been successfully installed.")
+	if i.frontend != nil {
+		time.Sleep(5 * time.Second)
+		i.frontend.Window.Close()
 	}
 
-	i.updateProgress(42, "Downloading the wallet SDK...")
-	err = i.checkAndFetchWalletCLI()
-	if err != nil {
-		i.sendErrorNotification("Unable to download CL files", fmt.Sprintf("%v", err))
-		time.Sleep(10 * time.Second)
-		log.Errorf("Unable to download CL files: %v", err)
-	}
+}
 
-	// Verify the integrity of the package
-	i.updateProgress(86, "Verifying Checksum...")
-	ok, err := i.VerifyChecksum(zippedArchive)
-	if err != nil || !ok {
-		i.sendErrorNotification("Checksum missmatch. Corrupted download", fmt.Sprintf("%v", err))
-		time.Sleep(10 * time.Second)
-		log.Fatalf("Checksum missmatch. Corrupted download: %v", err)
-	}
+// Cancel stops a running installation. Run rolls back the completed steps before returning.
+func (i *Install) Cancel() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
 
-	// Extract the contents
-	i.updateProgress(95, "Exctracting contents...")
-	contents, err := unzipArchive(zippedArchive, i.tmpFolderPath)
-	if err != nil {
-		i.sendErrorNotification("Unable to unzip contents", fmt.Sprintf("%v", err))
-		time.Sleep(10 * time.Second)
-		log.Fatalf("Unable to unzip contents: %v", err)
+	if i.cancel == nil {
+		log.Warnln("Cancel called while no installation is running")
+		return
 	}
+	log.Infoln("Cancelling installation...")
+	i.cancel()
+}
 
-	// Copy the contents (mollywallet and update) to the .dag folder
-	i.updateProgress(98, "Copy binaries...")
-	err = i.CopyAppBinaries(contents)
-	if err != nil {
-		i.sendErrorNotification("Unable to overwrite old installation", fmt.Sprintf("%v", err))
-		log.Errorf("Unable to overwrite old installation: %v", err)
+func (i *Install) setCancel(cancel context.CancelFunc) {
+	i.mu.Lock()
+	i.cancel = cancel
+	i.mu.Unlock()
+}
 
+// PrepareFS moves the previous installation aside, creates the install folder if missing and
+// creates the private temp folder of the run. restorePrevious puts the previous installation
+// back if the installation fails, removePrevious removes it once it succeeded.
+func (i *Install) PrepareFS() error {
+	// files slice will house the files that are to be moved aside before proceeding with installation.
+	files := i.installArtifacts()
+
+	// move the whole old .dag folder aside, the installer lock, the preferences and the installer run by
+	// scheduled update checks have to stay in place.
+	// Any other folder may hold files of the user, only the Molly Wallet files are replaced there.
+	owned := i.ownsInstallDir()
+	if !owned && !i.options.keepData {
+		log.Warnf("%s is not a Molly Wallet installation, only the Molly Wallet files in it are replaced", i.dagFolderPath)
+	}
+	if owned && !i.options.keepData {
+		entries, err := ioutil.ReadDir(i.dagFolderPath)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		files = nil
+		for _, entry := range entries {
+			switch entry.Name() {
+			case lockFileName, preferencesFileName, previousFolderName, scheduledInstallerName + i.OSSpecificSettings.fileExt:
+				continue
+			}
+			files = append(files, entry.Name())
+		}
 	}
 
-	i.updateProgress(100, "Installation Complete! Launching Molly Wallet...")
-	i.sendSuccessNotification("Success!", "Molly wallet has been successfully installed.")
-	time.Sleep(5 * time.Second)
-
-	// Lauch mollywallet
-	err = i.LaunchAppBinary()
+	err := i.moveAside(files)
 	if err != nil {
-		i.sendErrorNotification("Unable to start up Molly after Install", fmt.Sprintf("%v", err))
-		log.Errorf("Unable to start up Molly after Install: %v", err)
+		return fmt.Errorf("unable to move the previous installation aside: %v", err)
 	}
 
-	// Clean up install artifacts
-	err = i.CleanUp()
-	if err != nil {
-		i.sendErrorNotification("Unable to clear previous local state", fmt.Sprintf("%v", err))
-		time.Sleep(10 * time.Second)
-		log.Fatalf("Unable to clear previous local state: %v", err)
+	// create a new .dag folder with the right permissions
+	if !fileExists(i.dagFolderPath) {
+		err := os.MkdirAll(i.dagFolderPath, os.FileMode(0744))
+		if err != nil {
+			return fmt.Errorf("unable to create %s: %v", i.dagFolderPath, err)
+		}
 	}
 
-	i.frontend.Window.Close()
+	return i.makeTmpFolder()
+}
 
+// installArtifacts returns the names of the files the installer puts in the install folder
+func (i *Install) installArtifacts() []string {
+	files := []string{
+		"mollywallet" + i.OSSpecificSettings.fileExt, "update" + i.OSSpecificSettings.fileExt,
+		"cl-keytool.jar.tmp", "cl-keytool.jar", "cl-wallet.jar", "cl-wallet.jar.tmp", "Molly Wallet.lnk",
+		checksumFile, checksumFile + ".tmp", manifestFileName,
+	}
+	return append(files, packageFiles()...)
 }
 
-// PrepareFS removes uneccesary artifacts from the installation process and creates .dag folder if missing
-func (i *Install) PrepareFS() error {
-	// files slice will house the files that are to be removed before proceeding with installation.
-	files := make([]string, 8)
-	files = append(files, "cl-keytool.jar.tmp", "cl-keytool.jar", "cl-wallet.jar", "cl-wallet.jar.tmp", "mollywallet.zip", "mollywallet.zip.tmp", "Molly Wallet.lnk", "mollywallet.exe")
+// ownsInstallDir reports whether everything in the install folder belongs to Molly Wallet, so
+// it may be emptied or removed as a whole. That's the case for the default ~/.dag and for a
+// folder holding the manifest of an installation, but never for the home folder or a root.
+func (i *Install) ownsInstallDir() bool {
+	dir := filepath.Clean(i.dagFolderPath)
+	if dir == filepath.Dir(dir) {
+		return false
+	}
+	if homeDir, err := os.UserHomeDir(); err == nil {
+		if dir == filepath.Clean(homeDir) {
+			return false
+		}
+		if dir == filepath.Join(homeDir, ".dag") {
+			return true
+		}
+	}
+	return fileExists(i.manifestPath())
+}
 
-	removeFiles(i.dagFolderPath, files)
+// previousFolderName is the folder in the install folder the previous installation is kept in
+// until the installation succeeded
+const previousFolderName = ".previous"
 
-	// in case of a failed previous installation attempt, there may be extracted artifacts in .tmp
-	if fileExists(i.tmpFolderPath) {
-		err := os.RemoveAll(i.tmpFolderPath)
+// previousInstall is the previous installation moved aside by PrepareFS
+type previousInstall struct {
+	dir     string   // the folder the entries were moved to
+	entries []string // the names of the moved entries of the install folder
+	app     string   // where the macOS app was moved to, empty if there was none
+}
+
+// moveAside moves the named entries of the install folder and the macOS app out of the way of
+// the installation. Moving is atomic and, unlike removing, can be undone by restorePrevious.
+func (i *Install) moveAside(names []string) error {
+	dir := path.Join(i.dagFolderPath, previousFolderName)
+	if fileExists(dir) {
+		log.Warnf("Removing the previous installation left behind by an earlier run in %s", dir)
+		err := os.RemoveAll(dir)
 		if err != nil {
 			return err
 		}
 	}
+	i.previous = &previousInstall{dir: dir}
 
-	// remove the old .dag folder
-	folders := make([]string, 1)
-	folders = append(folders, path.Join(i.dagFolderPath))
-
-	if len(folders) != 0 {
-		err := removeFolders(folders)
+	for _, name := range names {
+		src := path.Join(i.dagFolderPath, name)
+		if _, err := os.Lstat(src); os.IsNotExist(err) {
+			continue
+		}
+		if len(i.previous.entries) == 0 {
+			err := os.Mkdir(dir, 0700)
+			if err != nil {
+				return err
+			}
+		}
+		err := copyRetryPolicy.retry(context.Background(), "moving "+name, func() error {
+			return os.Rename(src, path.Join(dir, name))
+		})
 		if err != nil {
-			i.sendErrorNotification("Error:", convertErrorToString(err))
-			log.Errorf("Error: %v", err)
+			return err
 		}
+		i.previous.entries = append(i.previous.entries, name)
 	}
 
-	// create a new .dag folder with the right permissions
-	if !fileExists(i.dagFolderPath) {
-		err := os.Mkdir(i.dagFolderPath, os.FileMode(0744))
+	// Move the .app folder aside on MacOS, it's hidden from Finder until it's removed
+	app := i.OSSpecificSettings.shortcutPath
+	if runtime.GOOS == "darwin" && fileExists(app) {
+		aside := path.Join(path.Dir(app), "."+path.Base(app)+previousFolderName)
+		err := os.RemoveAll(aside)
 		if err != nil {
-			i.sendErrorNotification("Unable to prepare filesystem", fmt.Sprintf("%v", err))
-			time.Sleep(10 * time.Second)
-			log.Fatalf("Unable to prepare filesystem: %v", err)
+			return err
 		}
-	}
-
-	// Remove the .app folder on MacOS
-	if runtime.GOOS == "darwin" && fileExists(i.OSSpecificSettings.shortcutPath) {
-		err := os.RemoveAll(i.OSSpecificSettings.shortcutPath)
+		err = os.Rename(app, aside)
 		if err != nil {
 			return err
 		}
+		i.previous.app = aside
 	}
 
+	if len(i.previous.entries) > 0 || i.previous.app != "" {
+		log.Infof("Moved the previous installation aside: %v", i.previous.entries)
+	}
 	return nil
 }
 
-// DownloadAppBinary downloads the latest Molly Wallet zip from github releases and returns the path to it
-func (i *Install) DownloadAppBinary() (string, error) {
-
-	filename := "mollywallet.zip"
-	version, err := i.getLatestRelease()
-	if err != nil {
-		return "", err
+// restorePrevious replaces whatever the installation put in place of the entries moved aside
+// by PrepareFS with the previous installation. If an entry can't be restored, the previous
+// installation is left in the previous folder so nothing is lost.
+func (i *Install) restorePrevious() error {
+	p := i.previous
+	if p == nil {
+		return nil
+	}
+
+	var failed []string
+	for _, name := range p.entries {
+		dst := path.Join(i.dagFolderPath, name)
+		err := os.RemoveAll(dst)
+		if err == nil {
+			err = copyRetryPolicy.retry(context.Background(), "restoring "+name, func() error {
+				return os.Rename(path.Join(p.dir, name), dst)
+			})
+		}
+		if err != nil {
+			log.Errorf("Unable to restore %s: %v", dst, err)
+			failed = append(failed, dst)
+		}
 	}
-
-	if i.OSSpecificSettings.osBuild == "unsupported" {
-		return "", fmt.Errorf("the OS is not supported")
+	if runtime.GOOS == "darwin" {
+		app := i.OSSpecificSettings.shortcutPath
+		err := os.RemoveAll(app)
+		if err == nil && p.app != "" {
+			err = os.Rename(p.app, app)
+		}
+		if err != nil {
+			log.Errorf("Unable to restore %s: %v", app, err)
+			failed = append(failed, app)
+		}
 	}
 
-	url := i.downloadURL + "/v" + version + "-" + i.OSSpecificSettings.osBuild + "/" + filename
-	// e.g https://github.com/grvlle/constellation_wallet/releases/download/v1.1.9-linux/mollywallet.zip
-	log.Infof("Constructed the following URL: %s", url)
-
-	filePath := path.Join(i.dagFolderPath, filename)
-	err = downloadFile(url, filePath)
-	if err != nil {
-		return "", fmt.Errorf("unable to download remote checksum: %v", err)
+	if len(failed) > 0 {
+		return fmt.Errorf("unable to restore %v, the previous installation is kept in %s", failed, p.dir)
 	}
-
-	return filePath, nil
+	i.previous = nil
+	if len(p.entries) > 0 {
+		log.Infof("Restored the previous installation: %v", p.entries)
+	}
+	return os.RemoveAll(p.dir)
 }
 
-// CheckAndFetchWalletCLI will download the cl-wallet dependencies from
-// the official Constellation Repo
-func (i *Install) checkAndFetchWalletCLI() error {
-
-	var downloadComplete bool
-
-	keytoolPath := path.Join(i.dagFolderPath, "cl-keytool.jar")
-	walletPath := path.Join(i.dagFolderPath, "cl-wallet.jar")
+// removePrevious removes the previous installation once it has been replaced
+func (i *Install) removePrevious() error {
+	p := i.previous
+	if p == nil {
+		return nil
+	}
+	i.previous = nil
+	if p.app != "" {
+		err := os.RemoveAll(p.app)
+		if err != nil {
+			return err
+		}
+	}
+	return os.RemoveAll(p.dir)
+}
 
-	err := i.fetchWalletJar("cl-keytool.jar", keytoolPath)
+// resolveRelease looks up the version to install and the metadata of its release the first
+// time it's called. Missing metadata isn't fatal, the package URL then falls back to mollywallet.zip.
+func (i *Install) resolveRelease(ctx context.Context) error {
+	if i.version != "" {
+		return nil
+	}
+	if i.options.version != "" {
+		return i.resolvePinnedRelease(ctx, i.options.version)
+	}
+	channel := Channel(i.GetChannel())
+	latest, err := i.github.channelRelease(ctx, channel, i.OSSpecificSettings.osBuild)
 	if err != nil {
-		log.Errorln("Unable to fetch or store cl-keytool.jar", err)
 		return err
 	}
-
-	err = i.fetchWalletJar("cl-wallet.jar", walletPath)
+	version, err := releaseVersion(latest.TagName)
 	if err != nil {
-		log.Errorln("Unable to fetch or store cl-wallet.jar", err)
 		return err
 	}
+	log.Infof("Latest Molly Wallet release on the %s channel is %s", channel, version)
+	i.version = version
 
-	if fileExists(keytoolPath) && fileExists(walletPath) {
-		downloadComplete = true
-	} else {
-		downloadComplete = false
+	if latest.TagName == i.releaseTag() {
+		i.release = latest
+		return nil
 	}
+	rel, err := i.github.releaseByTag(ctx, i.releaseTag())
+	if err != nil {
+		log.Warnf("Unable to fetch release metadata: %v", err)
+		return nil
+	}
+	i.release = rel
+	return nil
+}
 
-	if !downloadComplete {
-		err := errors.New("download failed")
+// resolvePinnedRelease looks up the release of version. Unlike the latest release, it's an
+// error if it can't be found.
+func (i *Install) resolvePinnedRelease(ctx context.Context, version string) error {
+	i.version = version
+	rel, err := i.github.releaseByTag(ctx, i.releaseTag())
+	if err != nil {
+		i.version = ""
 		return err
 	}
-	return err
+	log.Infof("Installing the pinned Molly Wallet release %s", version)
+	i.release = rel
+	return nil
+}
 
+// resetRelease forgets the resolved release, the next lookup picks up the latest one again
+func (i *Install) resetRelease() {
+	i.version = ""
+	i.release = nil
+	i.build = archFallback{}
+	i.checksumPath = ""
 }
 
-func (i *Install) fetchWalletJar(filename string, filePath string) error {
-	url := "https://github.com/Constellation-Labs/constellation/releases/download/v2.6.0/" + filename
-	log.Infof("Constructed the following URL: %s", url)
+// releaseTag returns the tag of the release for this OS, e.g v1.1.9-linux
+func (i *Install) releaseTag() string {
+	return "v" + i.version + "-" + i.OSSpecificSettings.osBuild
+}
 
-	filePath = path.Join(i.dagFolderPath, filename)
-	err := downloadFile(url, filePath)
-	if err != nil {
-		return fmt.Errorf("unable to download remote checksum: %v", err)
+// packageAsset returns the Molly Wallet package of the resolved release for this platform and
+// records the build it was picked from. Without release metadata it falls back to the amd64
+// package mollywallet.zip, if amd64 builds run here; the size is zero then.
+func (i *Install) packageAsset() (*releaseAsset, error) {
+	goos, goarch := runtime.GOOS, i.OSSpecificSettings.arch
+	if i.OSSpecificSettings.osBuild == "unsupported" {
+		return nil, &unsupportedPlatformError{os: goos, arch: goarch}
+	}
+
+	if i.release != nil {
+		asset, build, err := i.release.packageAsset(goos, goarch)
+		if err != nil {
+			return nil, err
+		}
+		i.setBuild(build)
+		return asset, nil
 	}
 
-	return err
+	for _, build := range platformArchs(goos, goarch) {
+		if build.arch != "amd64" {
+			continue
+		}
+		filename := packageName + ".zip"
+		log.Warnf("No release metadata, falling back to %s", filename)
+		i.setBuild(build)
+		return &releaseAsset{
+			Name: filename,
+			// e.g https://github.com/grvlle/constellation_wallet/releases/download/v1.1.9-linux/mollywallet.zip
+			BrowserDownloadURL: i.downloadURL + "/" + i.releaseTag() + "/" + filename,
+		}, nil
+	}
+	return nil, &unsupportedPlatformError{os: goos, arch: goarch}
+}
+
+func (i *Install) setBuild(build archFallback) {
+	if build.via != "" && build != i.build {
+		log.Infof("No %s build available, installing the %s build to run through %s", i.OSSpecificSettings.arch, build.arch, build.via)
+	}
+	i.build = build
+}
+
+// checksumFileName returns the name of the checksum asset of the picked build. Releases with
+// packages for several architectures publish a checksum per architecture.
+func (i *Install) checksumFileName() string {
+	name := "checksum-" + i.build.arch + ".sha256"
+	if i.release != nil && i.build.arch != "" && i.release.asset(name) != nil {
+		return name
+	}
+	return checksumFile
+}
+
+// checksumURL returns the URL of the checksum of the picked build, e.g
+// https://github.com/grvlle/constellation_wallet/releases/download/v1.1.9-linux/checksum.sha256
+func (i *Install) checksumURL() string {
+	return i.downloadURL + "/" + i.releaseTag() + "/" + i.checksumFileName()
 }
 
 // VerifyChecksum takes a file path and will check the file sha256 checksum against the checksum included
 // in the downlaod. Returns false if there's a missmatch.
-func (i *Install) VerifyChecksum(filePathZip string) (bool, error) {
+func (i *Install) VerifyChecksum(ctx context.Context, filePathArchive string) (bool, error) {
 
-	// Download checksum
-	filename := "checksum.sha256"
-	version, err := i.getLatestRelease()
-	if err != nil {
-		return false, err
-	}
+	// Download the checksum, unless it was downloaded along with the package
+	filePath := i.checksumPath
+	if filePath == "" {
+		err := i.resolveRelease(ctx)
+		if err != nil {
+			return false, err
+		}
 
-	if i.OSSpecificSettings.osBuild == "unsupported" {
-		return false, fmt.Errorf("the OS is not supported")
-	}
+		if i.OSSpecificSettings.osBuild == "unsupported" {
+			return false, &unsupportedPlatformError{os: runtime.GOOS, arch: i.OSSpecificSettings.arch}
+		}
 
-	url := i.downloadURL + "/v" + version + "-" + i.OSSpecificSettings.osBuild + "/" + filename
-	// e.g https://github.com/grvlle/constellation_wallet/releases/download/v1.1.9-linux/checksum.sha256
-	log.Infof("Constructed the following URL: %s", url)
+		url := i.checksumURL()
+		log.Infof("Constructed the following URL: %s", url)
 
-	filePath := path.Join(i.dagFolderPath, filename)
-	err = downloadFile(url, filePath)
-	if err != nil {
-		return false, fmt.Errorf("unable to download remote checksum: %v", err)
+		filePath = path.Join(i.dagFolderPath, checksumFile)
+		err = downloadFile(ctx, i.client, url, filePath, nil)
+		if err != nil {
+			return false, fmt.Errorf("unable to download remote checksum: %v", err)
+		}
 	}
 
 	// Read the contents of the downloaded file (remoteChecksum)
-	content, err := ioutil.ReadFile(filePath)
+	remoteChecksum, err := readChecksum(filePath)
 	if err != nil {
 		return false, err
 	}
-	lines := strings.Split(string(content), "\n")
-	remoteChecksum := lines[0]
 	log.Infof("Remote file checksum: %v", remoteChecksum)
 
-	// Collect the checksum of the downloaded zip (localChecksum)
-	f, err := os.Open(filePathZip)
+	// Collect the checksum of the downloaded package (localChecksum)
+	localChecksum, err := fileSHA256(filePathArchive)
 	if err != nil {
 		return false, err
 	}
-	defer f.Close()
-
-	hasher := sha256.New()
-	if _, err := io.Copy(hasher, f); err != nil {
-		return false, err
-	}
-	localChecksum := hex.EncodeToString(hasher.Sum(nil))
 	log.Infof("Local file checksum: %v", localChecksum)
 
 	return remoteChecksum == localChecksum, nil
 }
 
-// CopyAppBinaries copies the update module and molly binary from the unzipped package to the .dag folder.
-func (i *Install) CopyAppBinaries(contents *unzippedContents) error {
-	err := copyFile(contents.mollyBinaryPath, i.OSSpecificSettings.binaryPath)
+// readChecksum returns the SHA-256 in a checksum file, either just the hex encoded sum or the
+// output of sha256sum
+func readChecksum(filePath string) (string, error) {
+	content, err := ioutil.ReadFile(filePath)
 	if err != nil {
-		for n := 5; n > 0; n-- {
-			time.Sleep(time.Duration(n) * time.Second)
-			err = copyFile(contents.mollyBinaryPath, i.OSSpecificSettings.binaryPath)
-			if err == nil {
-				break
-			} else if err != nil && n == 0 {
-				return fmt.Errorf("unable to move the molly binary: %v", err)
-			}
-		}
+		return "", err
+	}
+	lines := strings.Split(string(content), "\n")
+	fields := strings.Fields(lines[0])
+	if len(fields) == 0 {
+		return "", fmt.Errorf("%s is empty", path.Base(filePath))
+	}
+	return strings.ToLower(fields[0]), nil
+}
+
+// CopyAppBinaries copies the update module and molly binary from the extracted package to the .dag folder.
+func (i *Install) CopyAppBinaries(ctx context.Context, contents *packageContents) error {
+	binaries := map[string]string{
+		contents.mollyBinaryPath: i.OSSpecificSettings.binaryPath,
 	}
 	// Replace old update binary with the new one
 	if fileExists(contents.updateBinaryPath) {
-		err = copyFile(contents.updateBinaryPath, i.dagFolderPath+"/update"+i.OSSpecificSettings.fileExt)
+		binaries[contents.updateBinaryPath] = path.Join(i.dagFolderPath, "update"+i.OSSpecificSettings.fileExt)
+	}
+
+	for src, dst := range binaries {
+		err := i.backupFile(dst)
+		if err != nil {
+			return fmt.Errorf("unable to back up %s: %v", dst, err)
+		}
+		err = copyRetryPolicy.retry(ctx, "copying "+path.Base(dst), func() error {
+			return copyFile(src, dst, executableFileMode)
+		})
 		if err != nil {
-			return fmt.Errorf("unable to copy update binary to .dag folder: %v", err)
+			return fmt.Errorf("unable to copy %s to %s: %v", path.Base(src), dst, err)
 		}
 	}
 	if runtime.GOOS == "darwin" {
@@ -361,23 +614,116 @@ func (i *Install) CopyAppBinaries(contents *unzippedContents) error {
 	}
 
 	if runtime.GOOS == "windows" {
-		err = createWindowsShortcuts(i.OSSpecificSettings.binaryPath, i.OSSpecificSettings.shortcutPath)
+		startMenuShortcut := path.Join(i.OSSpecificSettings.startMenuPath, "Molly Wallet.lnk")
+		desktopShortcut := path.Join(i.OSSpecificSettings.desktopPath, "Molly Wallet.lnk")
+		for _, shortcut := range []string{i.OSSpecificSettings.shortcutPath, startMenuShortcut, desktopShortcut} {
+			err := i.backupFile(shortcut)
+			if err != nil {
+				return fmt.Errorf("unable to back up %s: %v", shortcut, err)
+			}
+		}
+
+		err := createWindowsShortcuts(i.OSSpecificSettings.binaryPath, i.OSSpecificSettings.shortcutPath)
 		if err != nil {
 			return fmt.Errorf("unable to create app shortcut: %v", err)
 		}
-		err = copyFile(i.OSSpecificSettings.shortcutPath, path.Join(i.OSSpecificSettings.startMenuPath, "Molly Wallet.lnk"))
-		if err != nil {
-			return fmt.Errorf("unable to copy app shortcut to start menu: %v", err)
+		if i.options.shortcuts.StartMenu {
+			err = copyFile(i.OSSpecificSettings.shortcutPath, startMenuShortcut, dataFileMode)
+			if err != nil {
+				return fmt.Errorf("unable to copy app shortcut to start menu: %v", err)
+			}
+		}
+		if i.options.shortcuts.Desktop {
+			err = copyFile(i.OSSpecificSettings.shortcutPath, desktopShortcut, dataFileMode)
+			if err != nil {
+				return fmt.Errorf("unable to copy app shortcut to desktop: %v", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// backupFile copies an existing file aside so restoreBackups can put it back if
+// the installation fails. Files that don't exist yet are removed on restore.
+func (i *Install) backupFile(file string) error {
+	if i.backups == nil {
+		i.backups = make(map[string]string)
+	}
+	if _, ok := i.backups[file]; ok {
+		return nil
+	}
+	if !fileExists(file) {
+		i.backups[file] = ""
+		return nil
+	}
+
+	backupDir := path.Join(i.tmpFolderPath, "backup")
+	err := os.MkdirAll(backupDir, 0700)
+	if err != nil {
+		return err
+	}
+	backup := path.Join(backupDir, fmt.Sprintf("%d_%s", len(i.backups), path.Base(file)))
+	err = copyFile(file, backup, preserveFileMode)
+	if err != nil {
+		return err
+	}
+	i.backups[file] = backup
+	return nil
+}
+
+// restoreBackups puts back the files saved by backupFile
+func (i *Install) restoreBackups() error {
+	var failed []string
+	for file, backup := range i.backups {
+		var err error
+		if backup == "" {
+			err = os.Remove(file)
+			if os.IsNotExist(err) {
+				err = nil
+			}
+		} else {
+			err = copyFile(backup, file, preserveFileMode)
 		}
-		err = copyFile(i.OSSpecificSettings.shortcutPath, path.Join(i.OSSpecificSettings.desktopPath, "Molly Wallet.lnk"))
 		if err != nil {
-			return fmt.Errorf("unable to copy app shortcut to desktop: %v", err)
+			log.Errorf("Unable to restore %s: %v", file, err)
+			failed = append(failed, file)
+			continue
 		}
+		log.Infof("Restored %s", file)
 	}
+	i.backups = nil
 
+	if len(failed) > 0 {
+		return fmt.Errorf("unable to restore %v", failed)
+	}
 	return nil
 }
 
+// installedFiles lists the files put in place by the installation
+func (i *Install) installedFiles() []string {
+	candidates := []string{
+		i.OSSpecificSettings.binaryPath,
+		path.Join(i.dagFolderPath, "update"+i.OSSpecificSettings.fileExt),
+		path.Join(i.dagFolderPath, "cl-keytool.jar"),
+		path.Join(i.dagFolderPath, "cl-wallet.jar"),
+		i.OSSpecificSettings.shortcutPath,
+	}
+	if runtime.GOOS == "windows" {
+		candidates = append(candidates,
+			path.Join(i.OSSpecificSettings.startMenuPath, "Molly Wallet.lnk"),
+			path.Join(i.OSSpecificSettings.desktopPath, "Molly Wallet.lnk"))
+	}
+
+	var files []string
+	for _, file := range candidates {
+		if file != "" && fileExists(file) {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
 // LaunchAppBinary executes the new molly binary
 func (i *Install) LaunchAppBinary() error {
 	cmd := exec.Command(i.OSSpecificSettings.binaryPath)
@@ -397,15 +743,17 @@ func (i *Install) LaunchAppBinary() error {
 func (i *Install) CleanUp() error {
 
 	files := make([]string, 2)
-	files = append(files, "mollywallet.zip", "checksum.sha256")
+	files = append(files, checksumFile, checksumFile+".tmp")
+	files = append(files, packageFiles()...)
 
 	removeFiles(i.dagFolderPath, files)
 
-	if fileExists(i.tmpFolderPath) {
+	if i.tmpFolderPath != "" && fileExists(i.tmpFolderPath) {
 		err := os.RemoveAll(i.tmpFolderPath)
 		if err != nil {
 			return err
 		}
 	}
+	i.tmpFolderPath = ""
 	return nil
 }
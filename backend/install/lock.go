@@ -0,0 +1,108 @@
+package install
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const lockFileName = "installer.lock"
+
+// installLock is an OS level lock held in the .dag folder for the duration of an
+// install or uninstall, so two installer processes never work on the same files.
+type installLock struct {
+	path string
+	file *os.File
+}
+
+// acquireLock takes the installer lock in dir. A lock left behind by a process that is
+// no longer running is taken over.
+func acquireLock(dir string) (*installLock, error) {
+	err := os.MkdirAll(dir, os.FileMode(0744))
+	if err != nil {
+		return nil, err
+	}
+	lockPath := path.Join(dir, lockFileName)
+
+	// the previous owner is only known once we've read the file
+	owner := readLockOwner(lockPath)
+
+	f, err := lockFile(lockPath)
+	if err != nil {
+		owner = readLockOwner(lockPath)
+		if owner > 0 && owner != os.Getpid() && !processAlive(owner) {
+			log.Warnf("Removing stale installer lock held by pid %d", owner)
+			os.Remove(lockPath)
+			f, err = lockFile(lockPath)
+		}
+		if err != nil {
+			if owner > 0 {
+				return nil, fmt.Errorf("another installer (pid %d) is already running", owner)
+			}
+			return nil, fmt.Errorf("another installer is already running: %v", err)
+		}
+	} else if owner > 0 && owner != os.Getpid() {
+		log.Warnf("Taking over stale installer lock of pid %d", owner)
+	}
+
+	// record our pid for stale lock detection
+	if err := f.Truncate(0); err == nil {
+		f.WriteAt([]byte(strconv.Itoa(os.Getpid())), 0)
+		f.Sync()
+	}
+
+	return &installLock{path: lockPath, file: f}, nil
+}
+
+// release unlocks and removes the lock file
+func (l *installLock) release() {
+	if l == nil || l.file == nil {
+		return
+	}
+	// remove the file while still holding the lock. Another installer may still have opened it
+	// before, lockFile notices that it locked a removed file. Windows refuses to remove open
+	// files, so it's removed again after closing, which fails while another installer holds it.
+	os.Remove(l.path)
+	unlockFile(l.file)
+	l.file.Close()
+	l.file = nil
+	os.Remove(l.path)
+}
+
+// readLockOwner returns the pid recorded in the lock file, or 0 if unknown
+func readLockOwner(lockPath string) int {
+	data, err := ioutil.ReadFile(lockPath)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
+
+// begin marks the installer busy with op, rejecting re-entry while another
+// install or uninstall is in progress in this process.
+func (i *Install) begin(op string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.busy != "" {
+		return fmt.Errorf("unable to start %s, the %s is still in progress", op, i.busy)
+	}
+	i.busy = op
+	return nil
+}
+
+// end marks the installer idle again
+func (i *Install) end() {
+	i.mu.Lock()
+	i.busy = ""
+	i.mu.Unlock()
+}
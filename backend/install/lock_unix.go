@@ -0,0 +1,45 @@
+//go:build !windows
+// +build !windows
+
+package install
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile opens path and takes an exclusive, non-blocking flock on it. The lock is
+// released by the OS if the process dies.
+func lockFile(path string) (*os.File, error) {
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+		if err != nil {
+			return nil, err
+		}
+		err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+
+		// the previous owner removes the file when releasing it, possibly between our open and
+		// flock. A lock on the removed file excludes nobody, so lock the file at path instead.
+		locked, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		current, err := os.Stat(path)
+		if err == nil && os.SameFile(locked, current) {
+			return f, nil
+		}
+		f.Close()
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+}
+
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
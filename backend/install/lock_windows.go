@@ -0,0 +1,33 @@
+//go:build windows
+// +build windows
+
+package install
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile opens path without sharing it with any other handle, so it can't be opened by
+// another installer until it's closed. Windows releases the handle if the process dies.
+func lockFile(path string) (*os.File, error) {
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	h, err := syscall.CreateFile(name,
+		syscall.GENERIC_READ|syscall.GENERIC_WRITE,
+		0, // no sharing
+		nil,
+		syscall.OPEN_ALWAYS,
+		syscall.FILE_ATTRIBUTE_NORMAL,
+		0)
+	if err != nil {
+		return nil, err
+	}
+	return os.NewFile(uintptr(h), path), nil
+}
+
+func unlockFile(f *os.File) error {
+	return nil // closing the handle releases the lock
+}
@@ -0,0 +1,184 @@
+package install
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	logFileName   = "molly_wallet_install.log"
+	logFileMode   = 0600
+	logMaxSize    = 5 << 20 // rotate once the log grows past 5 MiB
+	logMaxBackups = 3
+)
+
+var (
+	runID   = newRunID()
+	stepMu  sync.RWMutex
+	curStep string
+)
+
+// RunID identifies the current installer run in the logs
+func RunID() string {
+	return runID
+}
+
+// InitLogger sets up JSON structured logging to a size-rotated file in the users home dir.
+// Every entry is tagged with the run ID and the name of the step being executed.
+func InitLogger() error {
+	log.SetFormatter(&log.JSONFormatter{})
+	log.SetLevel(log.InfoLevel)
+	log.AddHook(&runHook{})
+
+	logPath, err := logFilePath()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to locate log file, logging to stderr: %v\n", err)
+		return err
+	}
+
+	file, err := openRotatingFile(logPath, logMaxSize, logMaxBackups)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to open %s, logging to stderr: %v\n", logPath, err)
+		return err
+	}
+	log.SetOutput(file)
+
+	log.Infoln("--------------------------------- Logger Initialized -------------------------------------")
+	return nil
+}
+
+func logFilePath() (string, error) {
+	userDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(userDir, logFileName), nil
+}
+
+// setLogStep sets the step name attached to subsequent log entries
+func setLogStep(step string) {
+	stepMu.Lock()
+	curStep = step
+	stepMu.Unlock()
+}
+
+// logStep returns the name of the step being executed
+func logStep() string {
+	stepMu.RLock()
+	defer stepMu.RUnlock()
+	return curStep
+}
+
+func newRunID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// runHook adds the run ID and current step to every log entry
+type runHook struct{}
+
+func (h *runHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (h *runHook) Fire(entry *log.Entry) error {
+	entry.Data["run_id"] = runID
+
+	if step := logStep(); step != "" {
+		if _, ok := entry.Data["step"]; !ok {
+			entry.Data["step"] = step
+		}
+	}
+	return nil
+}
+
+// rotatingFile is an append-only log file that is rotated to path.1, path.2, ... once it
+// grows past maxSize bytes.
+type rotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+func openRotatingFile(path string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, logFileMode)
+	if err != nil {
+		return err
+	}
+	// older installers created the log world-writable
+	if err := f.Chmod(logFileMode); err != nil {
+		f.Close()
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.file = f
+	r.size = info.Size()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.size+int64(len(p)) > r.maxSize && r.size > 0 {
+		if err := r.rotate(); err != nil {
+			fmt.Fprintf(os.Stderr, "unable to rotate %s: %v\n", r.path, err)
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	for n := r.maxBackups - 1; n > 0; n-- {
+		os.Rename(fmt.Sprintf("%s.%d", r.path, n), fmt.Sprintf("%s.%d", r.path, n+1))
+	}
+	if r.maxBackups > 0 {
+		os.Rename(r.path, r.path+".1")
+	} else {
+		os.Remove(r.path)
+	}
+	return r.open()
+}
+
+// logFiles returns the current install log and its rotated backups
+func logFiles() []string {
+	logPath, err := logFilePath()
+	if err != nil {
+		return nil
+	}
+	files := []string{logPath}
+	for n := 1; n <= logMaxBackups; n++ {
+		files = append(files, fmt.Sprintf("%s.%d", logPath, n))
+	}
+	return files
+}
@@ -0,0 +1,49 @@
+package install
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path"
+	"time"
+)
+
+const manifestFileName = "install_manifest.json"
+
+// installManifest records what the installer put on disk
+type installManifest struct {
+	Version     string    `json:"version"`
+	OSBuild     string    `json:"os_build"`
+	Arch        string    `json:"arch,omitempty"`
+	Channel     Channel   `json:"channel,omitempty"`
+	Package     string    `json:"package"`
+	InstalledAt time.Time `json:"installed_at"`
+	RunID       string    `json:"run_id"`
+	Files       []string  `json:"files"`
+}
+
+func (i *Install) manifestPath() string {
+	return path.Join(i.dagFolderPath, manifestFileName)
+}
+
+// writeManifest stores the manifest of a completed installation in the .dag folder
+func (i *Install) writeManifest(m *installManifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(i.manifestPath(), data, dataFileMode)
+}
+
+// readManifest returns the manifest of the current installation
+func (i *Install) readManifest() (*installManifest, error) {
+	data, err := ioutil.ReadFile(i.manifestPath())
+	if err != nil {
+		return nil, err
+	}
+	m := &installManifest{}
+	err = json.Unmarshal(data, m)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,161 @@
+package install
+
+import (
+	"context"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Step is a single unit of work in the installer pipeline
+type Step interface {
+	// Name identifies the step in logs and events
+	Name() string
+	// Description is the status message shown while the step runs
+	Description() string
+	// Weight is the share of the overall progress taken up by the step, relative to the other steps
+	Weight() int
+	// Run does the work of the step, it should return promptly once ctx is cancelled
+	Run(ctx context.Context, i *Install) error
+	// Rollback undoes the work of the step after it or a later step failed
+	Rollback(i *Install) error
+	// Skip reports whether the step should be left out of this run
+	Skip(i *Install) bool
+	// State is the state of the installation while the step runs
+	State() State
+}
+
+// step is a Step built from plain functions. rollback and skip are optional.
+type step struct {
+	name        string
+	description string
+	failure     string // title of the error notification if the step fails
+	weight      int
+	state       State
+	run         func(ctx context.Context, i *Install) error
+	rollback    func(i *Install) error
+	skip        func(i *Install) bool
+}
+
+func (s *step) Name() string        { return s.name }
+func (s *step) Description() string { return s.description }
+func (s *step) Weight() int         { return s.weight }
+func (s *step) State() State        { return s.state }
+func (s *step) Run(ctx context.Context, i *Install) error {
+	return s.run(ctx, i)
+}
+
+func (s *step) Rollback(i *Install) error {
+	if s.rollback == nil {
+		return nil
+	}
+	return s.rollback(i)
+}
+
+func (s *step) Skip(i *Install) bool {
+	return s.skip != nil && s.skip(i)
+}
+
+// stepError is returned by runPipeline when a step fails
+type stepError struct {
+	step       Step
+	err        error
+	rolledBack bool // every step run so far was rolled back successfully
+}
+
+func (e *stepError) Error() string {
+	return fmt.Sprintf("%s: %v", e.step.Name(), e.err)
+}
+
+func (e *stepError) Unwrap() error {
+	return e.err
+}
+
+// title returns a user facing summary of the failure
+func (e *stepError) title() string {
+	if s, ok := e.step.(*step); ok && s.failure != "" {
+		return s.failure
+	}
+	return fmt.Sprintf("Installation step %q failed", e.step.Name())
+}
+
+// runPipeline runs the steps in order, computing the progress from their weights. Every step
+// emits a "step-start" and "step-end" event and moves the installation to the state of the step.
+// If a step fails or ctx is cancelled, the installation is marked Failed, the steps run so far
+// are rolled back in reverse order, the installation is marked RolledBack if that succeeded and
+// a *stepError is returned.
+func (i *Install) runPipeline(ctx context.Context, steps []Step) error {
+	var active []Step
+	var totalWeight int
+	for _, s := range steps {
+		if s.Skip(i) {
+			log.Infof("Skipping step %s", s.Name())
+			continue
+		}
+		active = append(active, s)
+		totalWeight += s.Weight()
+	}
+
+	var done int
+	for n, s := range active {
+		if err := ctx.Err(); err != nil {
+			log.Warnf("Installation cancelled before step %s", s.Name())
+			ok := i.fail(s, err, active[:n])
+			return &stepError{step: s, err: err, rolledBack: ok}
+		}
+
+		setLogStep(s.Name())
+		i.setState(s.State(), s.Name(), nil)
+		i.updateProgress(percentOf(done, totalWeight), percentOf(done+s.Weight(), totalWeight), s.Description())
+		i.emit("step-start", s.Name(), s.Description())
+
+		err := s.Run(ctx, i)
+		done += s.Weight()
+
+		if err != nil {
+			log.Errorf("Step %s failed: %v", s.Name(), err)
+			i.emit("step-end", s.Name(), err.Error())
+			ok := i.fail(s, err, active[:n+1])
+			return &stepError{step: s, err: err, rolledBack: ok}
+		}
+		i.emit("step-end", s.Name(), "")
+	}
+	setLogStep("")
+
+	return nil
+}
+
+// fail marks the installation as failed in step s and rolls back the steps run so far. Reports
+// whether they were all rolled back, the installation stays Failed otherwise.
+func (i *Install) fail(s Step, err error, steps []Step) bool {
+	i.setState(StateFailed, s.Name(), err)
+	if !i.rollback(steps) {
+		return false
+	}
+	i.setState(StateRolledBack, s.Name(), err)
+	return true
+}
+
+// rollback rolls back the given steps in reverse order. Failures are logged but don't stop the
+// remaining rollbacks. Reports whether every rollback succeeded.
+func (i *Install) rollback(steps []Step) bool {
+	ok := true
+	for n := len(steps) - 1; n >= 0; n-- {
+		s := steps[n]
+		setLogStep(s.Name())
+		err := s.Rollback(i)
+		if err != nil {
+			log.Errorf("Unable to roll back step %s: %v", s.Name(), err)
+			ok = false
+		}
+	}
+	setLogStep("")
+	return ok
+}
+
+func percentOf(done, total int) int {
+	if total == 0 {
+		return 100
+	}
+	return done * 100 / total
+}
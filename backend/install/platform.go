@@ -0,0 +1,60 @@
+package install
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// archFallback is a build of another architecture that runs through emulation
+type archFallback struct {
+	arch string
+	via  string // the emulation layer, shown to the user
+}
+
+// archFallbacks lists by GOOS/GOARCH the builds to fall back to, in order of preference, when a
+// release has no native build for the platform
+var archFallbacks = map[string][]archFallback{
+	"darwin/arm64":  {{arch: "amd64", via: "Rosetta 2"}},
+	"windows/arm64": {{arch: "amd64", via: "x64 emulation"}},
+}
+
+// unsupportedPlatformError is returned when there's no Molly Wallet build for the platform
+type unsupportedPlatformError struct {
+	os, arch  string
+	available []string // the architectures the release has builds for
+}
+
+func (e *unsupportedPlatformError) Error() string {
+	msg := fmt.Sprintf("Molly Wallet is not available for %s/%s", e.os, e.arch)
+	if len(e.available) > 0 {
+		msg += fmt.Sprintf(", this release has builds for %s", strings.Join(e.available, ", "))
+	}
+	return msg
+}
+
+// platformArchs returns the architectures whose builds run on goos/goarch, native first
+func platformArchs(goos, goarch string) []archFallback {
+	archs := []archFallback{{arch: goarch}}
+	return append(archs, archFallbacks[goos+"/"+goarch]...)
+}
+
+// packageAssetNames returns the names the package for arch may be published under, in order
+// of preference. Releases from before arm64 builds only have an amd64 package without suffix.
+func packageAssetNames(arch string) []string {
+	var names []string
+	for _, format := range archiveFormats {
+		names = append(names, packageName+"-"+arch+format.ext)
+	}
+	if arch == "amd64" {
+		for _, format := range archiveFormats {
+			names = append(names, packageName+format.ext)
+		}
+	}
+	return names
+}
+
+// rosettaInstalled reports whether Rosetta 2 can run x86_64 binaries on this Mac
+func rosettaInstalled() bool {
+	return exec.Command("arch", "-x86_64", "/usr/bin/true").Run() == nil
+}
@@ -0,0 +1,296 @@
+package install
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// extractedSizeFactor estimates the size of the extracted package from the size of the archive
+const extractedSizeFactor = 3
+
+// preflightTimeout bounds the reachability checks, so an unreachable host fails the preflight
+// instead of hanging it
+const preflightTimeout = 15 * time.Second
+
+// preflightCheck is the result of a single preflight check
+type preflightCheck struct {
+	Name    string `json:"name"`
+	Passed  bool   `json:"passed"`
+	Message string `json:"message"`
+}
+
+// preflightReport collects the results of all preflight checks
+type preflightReport struct {
+	Checks []preflightCheck `json:"checks"`
+}
+
+func (r *preflightReport) pass(name, format string, args ...interface{}) {
+	r.Checks = append(r.Checks, preflightCheck{Name: name, Passed: true, Message: fmt.Sprintf(format, args...)})
+}
+
+func (r *preflightReport) fail(name, format string, args ...interface{}) {
+	r.Checks = append(r.Checks, preflightCheck{Name: name, Message: fmt.Sprintf(format, args...)})
+}
+
+// failures returns the checks that didn't pass
+func (r *preflightReport) failures() []preflightCheck {
+	var failed []preflightCheck
+	for _, c := range r.Checks {
+		if !c.Passed {
+			failed = append(failed, c)
+		}
+	}
+	return failed
+}
+
+func (r *preflightReport) String() string {
+	var b strings.Builder
+	for _, c := range r.Checks {
+		result := "ok"
+		if !c.Passed {
+			result = "FAILED"
+		}
+		fmt.Fprintf(&b, "[%s] %s: %s\n", result, c.Name, c.Message)
+	}
+	return b.String()
+}
+
+// preflight checks that the installation can complete before anything on disk is changed: the
+// platform is supported, the release sources are reachable, the install folders are writable and
+// have enough free space for the release. Every check runs, even after one fails, and the report
+// is emitted as a "preflight" event. Returns an error listing the failed checks.
+func (i *Install) preflight(ctx context.Context) error {
+	r := &preflightReport{}
+
+	i.checkPlatform(r)
+	packageSize, sdkSize := i.checkReleaseSources(ctx, r)
+	i.checkPermissions(r)
+	i.checkDiskSpace(r, packageSize, sdkSize)
+
+	log.Infof("Preflight report:\n%s", r)
+	i.emit("preflight", r)
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	failed := r.failures()
+	if len(failed) == 0 {
+		return nil
+	}
+	msgs := make([]string, len(failed))
+	for n, c := range failed {
+		msgs[n] = c.Name + ": " + c.Message
+	}
+	return fmt.Errorf("%d preflight check(s) failed: %s", len(failed), strings.Join(msgs, "; "))
+}
+
+func (i *Install) checkPlatform(r *preflightReport) {
+	if i.OSSpecificSettings.osBuild == "unsupported" {
+		r.fail("platform", "%v", &unsupportedPlatformError{os: runtime.GOOS, arch: i.OSSpecificSettings.arch})
+		return
+	}
+	r.pass("platform", "%s/%s is supported", runtime.GOOS, i.OSSpecificSettings.arch)
+}
+
+// checkBuild checks the build picked for this platform can run, builds of other architectures
+// need their emulation layer to be installed
+func (i *Install) checkBuild(r *preflightReport) {
+	if i.build.via == "" {
+		return
+	}
+	if runtime.GOOS == "darwin" && !rosettaInstalled() {
+		r.fail("platform", "the %s build needs %s, install it with: softwareupdate --install-rosetta", i.build.arch, i.build.via)
+		return
+	}
+	r.pass("platform", "no %s build available, using the %s build through %s", i.OSSpecificSettings.arch, i.build.arch, i.build.via)
+}
+
+// checkReleaseSources resolves the release and checks the package and the wallet SDK can be
+// downloaded. Returns the download sizes, zero if unknown.
+func (i *Install) checkReleaseSources(ctx context.Context, r *preflightReport) (packageSize, sdkSize int64) {
+	err := i.resolveRelease(ctx)
+	if err != nil {
+		r.fail("release", "unable to look up the release: %v", err)
+		return 0, 0
+	}
+	r.pass("release", "Molly Wallet %s from the %s channel", i.version, i.GetChannel())
+
+	asset, err := i.packageAsset()
+	if _, ok := err.(*unsupportedPlatformError); ok {
+		r.fail("platform", "%v", err)
+	} else if err != nil {
+		r.fail("package", "%v", err)
+	} else {
+		i.checkBuild(r)
+		size, err := headSize(ctx, i.client, asset.BrowserDownloadURL)
+		if err != nil {
+			r.fail("package", "unable to reach %s: %v", asset.BrowserDownloadURL, err)
+		} else {
+			packageSize = asset.Size
+			if packageSize == 0 {
+				packageSize = size
+			}
+			r.pass("package", "%s (%s) is available", asset.Name, formatBytes(packageSize))
+		}
+	}
+
+	for _, jar := range walletSDKFiles {
+		size, err := headSize(ctx, i.client, walletSDKURL+jar)
+		if err != nil {
+			// the wallet works without the SDK, so it's not a preflight failure
+			log.Warnf("Unable to reach %s: %v", walletSDKURL+jar, err)
+			continue
+		}
+		sdkSize += size
+	}
+	return packageSize, sdkSize
+}
+
+// headSize checks url can be downloaded and returns its size, zero if the server doesn't tell
+func headSize(ctx context.Context, client *http.Client, url string) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, preflightTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected HTTP status: %s", resp.Status)
+	}
+	if resp.ContentLength < 0 {
+		return 0, nil
+	}
+	return resp.ContentLength, nil
+}
+
+// installDirs returns the folders the installer writes to, by purpose
+func (i *Install) installDirs() map[string]string {
+	dirs := map[string]string{
+		"install folder": i.dagFolderPath,
+		"temp folder":    i.config.TmpDir,
+	}
+	if binDir := filepath.Dir(i.OSSpecificSettings.binaryPath); filepath.Clean(binDir) != filepath.Clean(i.dagFolderPath) {
+		dirs["binary folder"] = binDir
+	}
+	switch runtime.GOOS {
+	case "darwin":
+		dirs["applications folder"] = filepath.Dir(i.OSSpecificSettings.shortcutPath)
+	case "windows":
+		dirs["start menu folder"] = i.OSSpecificSettings.startMenuPath
+		dirs["desktop folder"] = i.OSSpecificSettings.desktopPath
+	}
+	return dirs
+}
+
+func (i *Install) checkPermissions(r *preflightReport) {
+	dirs := i.installDirs()
+	for _, name := range sortedKeys(dirs) {
+		dir := dirs[name]
+		err := checkWritable(dir)
+		if err != nil {
+			r.fail("permissions", "%s %s is not writable: %v", name, dir, err)
+			continue
+		}
+		r.pass("permissions", "%s %s is writable", name, dir)
+	}
+}
+
+// checkWritable creates and removes a file in dir, or in its closest existing parent if dir
+// doesn't exist yet
+func checkWritable(dir string) error {
+	dir = existingParent(dir)
+	f, err := ioutil.TempFile(dir, ".molly-preflight-")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// checkDiskSpace checks the install and temp folders can hold the downloads, the extracted
+// package and the backups of the current installation
+func (i *Install) checkDiskSpace(r *preflightReport, packageSize, sdkSize int64) {
+	if packageSize == 0 {
+		r.pass("disk space", "skipped, the package size is unknown")
+		return
+	}
+	extracted := packageSize * extractedSizeFactor
+
+	var backups int64
+	for _, file := range i.installedFiles() {
+		if fi, err := os.Stat(file); err == nil && !fi.IsDir() {
+			backups += fi.Size()
+		}
+	}
+
+	required := map[string]int64{
+		// the package, the wallet SDK and the installed binaries
+		i.dagFolderPath: packageSize + sdkSize + extracted,
+		// the extracted package and the backups
+		i.config.TmpDir: extracted + backups,
+	}
+	for _, dir := range []string{i.dagFolderPath, i.config.TmpDir} {
+		free, err := freeDiskSpace(existingParent(dir))
+		if err != nil {
+			r.fail("disk space", "unable to check the free disk space of %s: %v", dir, err)
+			continue
+		}
+		if uint64(required[dir]) > free {
+			r.fail("disk space", "%s needs %s but only %s are free", dir, formatBytes(required[dir]), formatBytes(int64(free)))
+			continue
+		}
+		r.pass("disk space", "%s has %s free, %s needed", dir, formatBytes(int64(free)), formatBytes(required[dir]))
+	}
+}
+
+// existingParent returns dir, or its closest parent that exists
+func existingParent(dir string) string {
+	dir = filepath.Clean(dir)
+	for !fileExists(dir) {
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return dir
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,259 @@
+package install
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// process describes a running OS process
+type process struct {
+	pid  int
+	name string
+	path string // path to the executable, empty if unknown
+}
+
+// stopRunningApps detects running mollywallet and, if withUpdate is set, update processes and
+// stops them before any files in the .dag folder are touched. Unless closeRunningApps is set,
+// the user is prompted for permission first.
+func (i *Install) stopRunningApps(withUpdate bool) error {
+	procs, err := i.findAppProcesses(withUpdate)
+	if err != nil {
+		return fmt.Errorf("unable to list running processes: %v", err)
+	}
+	if len(procs) == 0 {
+		return nil
+	}
+	log.Infof("Detected running Molly Wallet processes: %v", procs)
+
+	if !i.closeRunningApps {
+		ok := i.prompt("Molly Wallet is running", "Molly Wallet needs to be closed before it can be updated. Close it now?")
+		if !ok {
+			return fmt.Errorf("molly wallet is still running, please close it and try again")
+		}
+	}
+
+	for _, p := range procs {
+		log.Infof("Stopping %s (pid %d)", p.name, p.pid)
+		err := stopProcess(p)
+		if err != nil {
+			log.Warnf("unable to signal %s (pid %d): %v", p.name, p.pid, err)
+		}
+	}
+
+	// give the wallet some time to shut down gracefully
+	deadline := time.Now().Add(15 * time.Second)
+	for time.Now().Before(deadline) {
+		procs, err = i.findAppProcesses(withUpdate)
+		if err != nil {
+			return fmt.Errorf("unable to list running processes: %v", err)
+		}
+		if len(procs) == 0 {
+			return nil
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	return fmt.Errorf("unable to stop running Molly Wallet processes %v. Please close them manually and try again", procs)
+}
+
+// prompt asks the user a yes/no question through the frontend and waits for the answer
+func (i *Install) prompt(title, msg string) bool {
+	if i.frontend == nil {
+		return false
+	}
+	i.emit("prompt", title, msg)
+
+	select {
+	case answer := <-i.promptCh:
+		return answer
+	case <-time.After(5 * time.Minute):
+		log.Warnf("No answer received for prompt: %s", title)
+		return false
+	}
+}
+
+// AnswerPrompt is called from the frontend with the users answer to a "prompt" event
+func (i *Install) AnswerPrompt(answer bool) {
+	select {
+	case i.promptCh <- answer:
+	default:
+		log.Warnln("Received a prompt answer while no prompt was pending")
+	}
+}
+
+// SetCloseRunningApps makes the installer stop running wallet processes without prompting
+func (i *Install) SetCloseRunningApps(close bool) {
+	i.closeRunningApps = close
+}
+
+func (p process) String() string {
+	return fmt.Sprintf("%s (pid %d)", p.name, p.pid)
+}
+
+// findAppProcesses returns the running mollywallet processes and, if withUpdate is set, the
+// update helper processes started from the .dag folder. "update" is too generic a name to
+// match on its own, so it's only matched when the executable path is known and inside .dag.
+func (i *Install) findAppProcesses(withUpdate bool) ([]process, error) {
+	names := []string{"mollywallet" + i.OSSpecificSettings.fileExt}
+	if withUpdate {
+		names = append(names, "update"+i.OSSpecificSettings.fileExt)
+	}
+	procs, err := findProcesses(names)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []process
+	for _, p := range procs {
+		if strings.HasPrefix(strings.ToLower(p.name), "update") && !pathInside(p.path, i.dagFolderPath) {
+			continue
+		}
+		matches = append(matches, p)
+	}
+	return matches, nil
+}
+
+// pathInside reports whether file is inside dir. An unknown, empty file is never inside.
+func pathInside(file, dir string) bool {
+	if file == "" || dir == "" {
+		return false
+	}
+	rel, err := filepath.Rel(filepath.Clean(dir), filepath.Clean(file))
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// findProcesses returns the running processes whose executable name matches one of names
+func findProcesses(names []string) ([]process, error) {
+	var (
+		procs []process
+		err   error
+	)
+
+	if runtime.GOOS == "windows" {
+		procs, err = listWindowsProcesses()
+	} else {
+		procs, err = listUnixProcesses()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []process
+	for _, p := range procs {
+		if p.pid == os.Getpid() {
+			continue
+		}
+		for _, name := range names {
+			if strings.EqualFold(p.name, name) {
+				matches = append(matches, p)
+			}
+		}
+	}
+	return matches, nil
+}
+
+func listUnixProcesses() ([]process, error) {
+	out, err := exec.Command("ps", "-A", "-o", "pid=", "-o", "comm=").Output()
+	if err != nil {
+		return nil, fmt.Errorf("unable to run ps: %v", err)
+	}
+
+	var procs []process
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		p := process{pid: pid, name: filepath.Base(strings.Join(fields[1:], " "))}
+
+		// on MacOS comm is the full path to the executable, on Linux it's in /proc
+		if strings.HasPrefix(fields[1], "/") {
+			p.path = strings.Join(fields[1:], " ")
+		} else {
+			p.path = processPath(pid)
+		}
+		procs = append(procs, p)
+	}
+	return procs, nil
+}
+
+func listWindowsProcesses() ([]process, error) {
+	out, err := exec.Command("tasklist", "/FO", "CSV", "/NH").Output()
+	if err != nil {
+		return nil, fmt.Errorf("unable to run tasklist: %v", err)
+	}
+
+	records, err := csv.NewReader(bytes.NewReader(out)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse tasklist output: %v", err)
+	}
+
+	var procs []process
+	for _, record := range records {
+		if len(record) < 2 {
+			continue
+		}
+		pid, err := strconv.Atoi(record[1])
+		if err != nil {
+			continue
+		}
+		procs = append(procs, process{pid: pid, name: record[0], path: processPath(pid)})
+	}
+	return procs, nil
+}
+
+// processAlive reports whether a process with the given pid is running
+func processAlive(pid int) bool {
+	if runtime.GOOS == "windows" {
+		procs, err := listWindowsProcesses()
+		if err != nil {
+			return true // assume the worst
+		}
+		for _, p := range procs {
+			if p.pid == pid {
+				return true
+			}
+		}
+		return false
+	}
+
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	// signal 0 only checks for existence, EPERM means it exists but belongs to someone else
+	err = proc.Signal(syscall.Signal(0))
+	return err == nil || err == syscall.EPERM
+}
+
+// stopProcess asks a process to exit gracefully
+func stopProcess(p process) error {
+	if runtime.GOOS == "windows" {
+		// without /F taskkill sends WM_CLOSE, letting the wallet shut down cleanly
+		return exec.Command("taskkill", "/PID", strconv.Itoa(p.pid)).Run()
+	}
+
+	proc, err := os.FindProcess(p.pid)
+	if err != nil {
+		return err
+	}
+	return proc.Signal(syscall.SIGTERM)
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+// +build !windows
+
+package install
+
+import (
+	"fmt"
+	"os"
+)
+
+// processPath returns the path to the executable of the process, empty if it can't be read,
+// e.g because the process belongs to another user. Only linux exposes it through /proc.
+func processPath(pid int) string {
+	exe, err := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid))
+	if err != nil {
+		return ""
+	}
+	return exe
+}
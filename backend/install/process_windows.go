@@ -0,0 +1,31 @@
+//go:build windows
+// +build windows
+
+package install
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// processQueryLimitedInformation is enough to read the image name of processes of other users
+const processQueryLimitedInformation = 0x1000
+
+var procQueryFullProcessImageName = syscall.NewLazyDLL("kernel32.dll").NewProc("QueryFullProcessImageNameW")
+
+// processPath returns the path to the executable of the process, empty if it can't be read
+func processPath(pid int) string {
+	h, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		return ""
+	}
+	defer syscall.CloseHandle(h)
+
+	buf := make([]uint16, syscall.MAX_LONG_PATH)
+	size := uint32(len(buf))
+	r, _, _ := procQueryFullProcessImageName.Call(uintptr(h), 0, uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&size)))
+	if r == 0 {
+		return ""
+	}
+	return syscall.UTF16ToString(buf[:size])
+}
@@ -0,0 +1,224 @@
+package install
+
+import (
+	"sync"
+	"time"
+)
+
+// progressEngine drives the progress bar. Between updates it slowly creeps towards the
+// ceiling set by the current step, so long running steps still show movement, but it never
+// runs ahead into the next step. Updates never block: they're applied under a mutex and a
+// single goroutine emits the latest progress and status, so a slow consumer only causes
+// intermediate values to be skipped.
+type progressEngine struct {
+	lifecycle sync.Mutex // serializes start and stop
+	mu        sync.Mutex
+	emit      func(event string, data ...interface{})
+	interval  time.Duration // how often the bar creeps forward
+
+	percent int
+	floor   int // the progress requested by the last update, where the current step starts
+	ceiling int
+	message string
+	running bool
+
+	emittedPercent int // the last emitted values, only touched by the emitting goroutine
+	emittedMessage string
+
+	notifyCh chan struct{} // wakes up the emitting goroutine, holds at most one pending signal
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+}
+
+func newProgressEngine(emit func(event string, data ...interface{}), interval time.Duration) *progressEngine {
+	return &progressEngine{emit: emit, interval: interval}
+}
+
+// start resets the progress and starts creeping. Calling start on a running engine is a no-op.
+func (p *progressEngine) start() {
+	p.lifecycle.Lock()
+	defer p.lifecycle.Unlock()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.running {
+		return
+	}
+	p.percent = 0
+	p.floor = 0
+	p.ceiling = 0
+	p.message = ""
+	p.running = true
+	p.emittedPercent = -1
+	p.emittedMessage = ""
+	p.notifyCh = make(chan struct{}, 1)
+	p.stopCh = make(chan struct{})
+
+	p.wg.Add(2)
+	go p.creep(p.stopCh)
+	go p.emitChanges(p.notifyCh, p.stopCh)
+
+	p.notify()
+}
+
+// update moves the progress bar to percent and lets it creep up to ceiling until the next
+// update. The progress never moves backwards. An empty msg keeps the current status message.
+func (p *progressEngine) update(percent, ceiling int, msg string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.running {
+		return
+	}
+	if percent > p.percent {
+		p.percent = clampPercent(percent)
+	}
+	p.floor = clampPercent(percent)
+	p.ceiling = clampPercent(ceiling)
+	if msg != "" {
+		p.message = msg
+	}
+	p.notify()
+}
+
+// fraction moves the progress bar fraction f (0-1) of the way from the start of the current
+// step to its ceiling, for steps that can tell how far along they are. Like update, it never
+// moves the bar backwards and an empty msg keeps the current status message.
+func (p *progressEngine) fraction(f float64, msg string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.running {
+		return
+	}
+	if f < 0 {
+		f = 0
+	} else if f > 1 {
+		f = 1
+	}
+	percent := p.floor + int(f*float64(p.ceiling-p.floor))
+	if percent > p.percent {
+		p.percent = clampPercent(percent)
+	}
+	if msg != "" {
+		p.message = msg
+	}
+	p.notify()
+}
+
+// status changes the status message without moving the progress bar
+func (p *progressEngine) status(msg string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.running {
+		return
+	}
+	p.message = msg
+	p.notify()
+}
+
+// stop stops creeping and emits the final state. Once stop returns, nothing is emitted until
+// the engine is started again, updates in between are ignored. A negative percent and an
+// empty msg keep the current values. Calling stop on a stopped engine is a no-op.
+func (p *progressEngine) stop(percent int, msg string) {
+	p.lifecycle.Lock()
+	defer p.lifecycle.Unlock()
+	p.mu.Lock()
+	if !p.running {
+		p.mu.Unlock()
+		return
+	}
+	p.running = false
+	if percent >= 0 {
+		p.percent = clampPercent(percent)
+	}
+	if msg != "" {
+		p.message = msg
+	}
+	close(p.stopCh)
+	p.mu.Unlock()
+
+	// wait outside the lock, the goroutines may be waiting for it
+	p.wg.Wait()
+}
+
+// snapshot returns the current percentage and status message
+func (p *progressEngine) snapshot() (int, string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.percent, p.message
+}
+
+// notify wakes up the emitting goroutine without ever blocking. p.mu must be held.
+func (p *progressEngine) notify() {
+	select {
+	case p.notifyCh <- struct{}{}:
+	default: // a wake up is pending already, it picks up this change as well
+	}
+}
+
+func (p *progressEngine) creep(stopCh chan struct{}) {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			p.mu.Lock()
+			// stay one percent below the ceiling, reaching it is up to the next update
+			if p.running && p.percent < p.ceiling-1 {
+				p.percent++
+				p.notify()
+			}
+			p.mu.Unlock()
+		}
+	}
+}
+
+// emitChanges emits the progress and status whenever they changed, until the engine is
+// stopped. The final state is emitted before it returns.
+func (p *progressEngine) emitChanges(notifyCh, stopCh chan struct{}) {
+	defer p.wg.Done()
+
+	for {
+		select {
+		case <-notifyCh:
+			p.emitLatest()
+		case <-stopCh:
+			p.emitLatest()
+			return
+		}
+	}
+}
+
+// emitLatest emits the current progress and status, if they changed since they were last emitted
+func (p *progressEngine) emitLatest() {
+	p.mu.Lock()
+	percent, msg := p.percent, p.message
+	p.mu.Unlock()
+
+	// emit outside the lock, so a slow consumer never blocks updates
+	if percent != p.emittedPercent {
+		p.emittedPercent = percent
+		p.emit("progress", percent)
+	}
+	if msg != p.emittedMessage {
+		p.emittedMessage = msg
+		p.emit("status", msg)
+	}
+}
+
+func clampPercent(percent int) int {
+	if percent < 0 {
+		return 0
+	}
+	if percent > 100 {
+		return 100
+	}
+	return percent
+}
@@ -0,0 +1,113 @@
+package install
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder collects the events emitted by a progress engine
+type recorder struct {
+	mu     sync.Mutex
+	delay  time.Duration // how long every emit takes
+	events []string
+	closed bool // set once the engine is stopped, nothing may be emitted after
+	late   []string
+}
+
+func (r *recorder) emit(event string, data ...interface{}) {
+	time.Sleep(r.delay)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		r.late = append(r.late, event)
+	}
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) close() {
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
+}
+
+func TestProgressStartStopIdempotent(t *testing.T) {
+	r := &recorder{}
+	p := newProgressEngine(r.emit, time.Millisecond)
+
+	p.stop(-1, "") // stopping an engine that never started is a no-op
+	p.start()
+	p.start()
+	p.update(10, 20, "step")
+	p.stop(50, "done")
+	p.stop(-1, "")
+
+	if percent, msg := p.snapshot(); percent != 50 || msg != "done" {
+		t.Errorf("snapshot after stop = %d %q, want 50 \"done\"", percent, msg)
+	}
+
+	// a stopped engine starts over
+	p.start()
+	if percent, msg := p.snapshot(); percent != 0 || msg != "" {
+		t.Errorf("snapshot after restart = %d %q, want 0 \"\"", percent, msg)
+	}
+	p.stop(100, "")
+	if percent, _ := p.snapshot(); percent != 100 {
+		t.Errorf("percent after second stop = %d, want 100", percent)
+	}
+}
+
+func TestProgressUpdateDoesNotBlock(t *testing.T) {
+	r := &recorder{delay: 100 * time.Millisecond}
+	p := newProgressEngine(r.emit, time.Millisecond)
+	p.start()
+
+	begin := time.Now()
+	for n := 0; n < 100; n++ {
+		p.update(n, n+1, "step")
+		p.fraction(0.5, "")
+		p.status("status")
+	}
+	if elapsed := time.Since(begin); elapsed > 500*time.Millisecond {
+		t.Errorf("100 updates with a slow emitter took %s", elapsed)
+	}
+
+	p.stop(100, "done")
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// intermediate values are skipped, but the final state is always emitted
+	if n := len(r.events); n == 0 || n > 10 {
+		t.Errorf("emitted %d events, want the latest values only: %v", n, r.events)
+	}
+	if last := r.events[len(r.events)-1]; last != "status" {
+		t.Errorf("last event = %s, want the final status", last)
+	}
+	if percent, msg := p.snapshot(); percent != 100 || msg != "done" {
+		t.Errorf("snapshot after stop = %d %q, want 100 \"done\"", percent, msg)
+	}
+}
+
+func TestProgressNoEmitsAfterStop(t *testing.T) {
+	r := &recorder{delay: time.Millisecond}
+	p := newProgressEngine(r.emit, time.Millisecond)
+	p.start()
+	p.update(0, 100, "creeping")
+	time.Sleep(20 * time.Millisecond)
+
+	p.stop(-1, "stopped")
+	r.close()
+
+	p.update(60, 70, "late")
+	p.fraction(1, "late")
+	p.status("late")
+	time.Sleep(20 * time.Millisecond) // creeping would have moved the bar by now
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.late) > 0 {
+		t.Errorf("emitted %v after stop", r.late)
+	}
+	if _, msg := p.snapshot(); msg != "stopped" {
+		t.Errorf("status after stop = %q, want \"stopped\"", msg)
+	}
+}
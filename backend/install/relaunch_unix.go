@@ -0,0 +1,20 @@
+//go:build !windows
+// +build !windows
+
+package install
+
+import (
+	"os"
+	"syscall"
+)
+
+// Relaunch replaces the running installer with the updated executable, keeping the arguments,
+// the process and the terminal. It only returns if that fails.
+func Relaunch() error {
+	exe, err := executablePath()
+	if err != nil {
+		return err
+	}
+	env := append(os.Environ(), relaunchedEnv+"=1")
+	return syscall.Exec(exe, os.Args, env)
+}
@@ -0,0 +1,28 @@
+//go:build windows
+// +build windows
+
+package install
+
+import (
+	"os"
+	"os/exec"
+)
+
+// Relaunch runs the updated installer with the same arguments and exits with its exit code once
+// it's done, windows can't replace the running process. It only returns if the start fails.
+func Relaunch() error {
+	exe, err := executablePath()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.Env = append(os.Environ(), relaunchedEnv+"=1")
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+	cmd.Wait()
+	os.Exit(cmd.ProcessState.ExitCode())
+	return nil
+}
@@ -0,0 +1,203 @@
+package install
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver"
+)
+
+const releasesAPIURL = "https://api.github.com/repos/grvlle/constellation_wallet/releases"
+
+// walletSDKURL is where the wallet SDK jars are downloaded from
+const walletSDKURL = "https://github.com/Constellation-Labs/constellation/releases/download/v2.6.0/"
+
+// walletSDKFiles are the jars of the wallet SDK
+var walletSDKFiles = []string{"cl-keytool.jar", "cl-wallet.jar"}
+
+// packageName is the base name of the Molly Wallet package asset, the extension depends on the archive format
+const packageName = "mollywallet"
+
+// checksumFile is the name the checksum of the package is stored under in the .dag folder
+const checksumFile = "checksum.sha256"
+
+// release is the subset of the GitHub release metadata used by the installer
+type release struct {
+	TagName     string         `json:"tag_name"`
+	Name        string         `json:"name"`
+	Body        string         `json:"body"` // the release notes in markdown
+	HTMLURL     string         `json:"html_url"`
+	Prerelease  bool           `json:"prerelease"`
+	Draft       bool           `json:"draft"`
+	PublishedAt time.Time      `json:"published_at"`
+	Assets      []releaseAsset `json:"assets"`
+}
+
+// releaseAsset is a file attached to a GitHub release
+type releaseAsset struct {
+	Name               string `json:"name"`
+	Size               int64  `json:"size"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+}
+
+// releaseVersion returns the version of a release tag, e.g 1.1.9 for v1.1.9-linux
+func releaseVersion(tag string) (string, error) {
+	version := strings.TrimPrefix(tag, "v")
+	for _, osBuild := range []string{"darwin", "linux", "windows"} {
+		version = strings.TrimSuffix(version, "-"+osBuild)
+	}
+	if _, err := semver.NewVersion(version); err != nil {
+		return "", fmt.Errorf("release tag %s has no valid version: %v", tag, err)
+	}
+	return version, nil
+}
+
+// packageAsset returns the Molly Wallet package attached to the release for goos/goarch and the
+// build it was picked from. A native build wins over the fallbacks in archFallbacks. If a build
+// is published in several archive formats, the first one listed in archiveFormats wins.
+func (r *release) packageAsset(goos, goarch string) (*releaseAsset, archFallback, error) {
+	for _, build := range platformArchs(goos, goarch) {
+		for _, name := range packageAssetNames(build.arch) {
+			if asset := r.asset(name); asset != nil {
+				return asset, build, nil
+			}
+		}
+	}
+
+	available := r.packageArchs()
+	if len(available) == 0 {
+		return nil, archFallback{}, fmt.Errorf("release %s has no Molly Wallet package in a supported format", r.TagName)
+	}
+	return nil, archFallback{}, &unsupportedPlatformError{os: goos, arch: goarch, available: available}
+}
+
+// asset returns the asset called name, or nil if the release has none
+func (r *release) asset(name string) *releaseAsset {
+	for n, asset := range r.Assets {
+		if strings.EqualFold(asset.Name, name) {
+			return &r.Assets[n]
+		}
+	}
+	return nil
+}
+
+// packageArchs returns the architectures the release has a Molly Wallet package for
+func (r *release) packageArchs() []string {
+	var archs []string
+	seen := map[string]bool{}
+	for _, asset := range r.Assets {
+		for _, format := range archiveFormats {
+			name := strings.ToLower(asset.Name)
+			if !strings.HasPrefix(name, packageName) || !strings.HasSuffix(name, format.ext) {
+				continue
+			}
+			arch := strings.TrimPrefix(strings.TrimSuffix(name, format.ext), packageName)
+			if arch == "" {
+				arch = "amd64"
+			}
+			arch = strings.TrimPrefix(arch, "-")
+			if !seen[arch] {
+				seen[arch] = true
+				archs = append(archs, arch)
+			}
+			break
+		}
+	}
+	return archs
+}
+
+// packageFiles lists every file name a downloaded package may have on this platform, including
+// partial downloads
+func packageFiles() []string {
+	var files []string
+	for _, build := range platformArchs(runtime.GOOS, runtime.GOARCH) {
+		for _, name := range packageAssetNames(build.arch) {
+			files = append(files, name, name+".tmp")
+		}
+	}
+	return files
+}
+
+// ReleaseInfo describes the release the next installation installs
+type ReleaseInfo struct {
+	Version     string    `json:"version"`
+	Tag         string    `json:"tag"`
+	Name        string    `json:"name"`
+	Channel     string    `json:"channel"`
+	Prerelease  bool      `json:"prerelease"`
+	PublishedAt time.Time `json:"published_at"`
+	Asset       string    `json:"asset"`
+	Size        int64     `json:"size"` // zero if unknown
+	Arch        string    `json:"arch"` // the architecture of the build, see archFallbacks
+	Emulation   string    `json:"emulation"`
+	Notes       string    `json:"notes"` // markdown
+	URL         string    `json:"url"`
+}
+
+// GetReleaseInfo looks up the release the next installation installs, so it can be confirmed
+// before installing. Run installs the same release.
+func (i *Install) GetReleaseInfo() (*ReleaseInfo, error) {
+	err := i.begin("release lookup")
+	if err != nil {
+		return nil, err
+	}
+	defer i.end()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	err = i.resolveRelease(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to look up the latest release: %v", err)
+	}
+	asset, err := i.packageAsset()
+	if err != nil {
+		return nil, err
+	}
+
+	info := &ReleaseInfo{
+		Version:   i.version,
+		Tag:       i.releaseTag(),
+		Channel:   i.GetChannel(),
+		Asset:     asset.Name,
+		Size:      asset.Size,
+		Arch:      i.build.arch,
+		Emulation: i.build.via,
+	}
+	if i.release != nil {
+		info.Name = i.release.Name
+		info.Prerelease = i.release.Prerelease
+		info.PublishedAt = i.release.PublishedAt
+		info.Notes = i.release.Body
+		info.URL = i.release.HTMLURL
+	}
+	return info, nil
+}
+
+// Summary describes the release in plain text, followed by the release notes
+func (r *ReleaseInfo) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Molly Wallet %s (%s)\n", r.Version, r.Tag)
+	fmt.Fprintf(&b, "Channel:   %s\n", r.Channel)
+	if !r.PublishedAt.IsZero() {
+		fmt.Fprintf(&b, "Published: %s\n", r.PublishedAt.Local().Format("2006-01-02 15:04"))
+	}
+	size := "unknown size"
+	if r.Size > 0 {
+		size = formatBytes(r.Size)
+	}
+	fmt.Fprintf(&b, "Package:   %s (%s, %s)\n", r.Asset, size, r.Arch)
+	if r.Emulation != "" {
+		fmt.Fprintf(&b, "           runs through %s\n", r.Emulation)
+	}
+	if r.URL != "" {
+		fmt.Fprintf(&b, "Details:   %s\n", r.URL)
+	}
+	if notes := strings.TrimSpace(r.Notes); notes != "" {
+		fmt.Fprintf(&b, "\n%s\n", notes)
+	}
+	return b.String()
+}
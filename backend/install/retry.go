@@ -0,0 +1,124 @@
+package install
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// retryPolicy describes how many times and how quickly a failing operation is retried
+type retryPolicy struct {
+	attempts   int           // total number of attempts, including the first one
+	delay      time.Duration // delay before the first retry
+	maxDelay   time.Duration // upper bound for the delay between attempts
+	multiplier float64       // backoff factor applied to the delay after every failed attempt
+	jitter     float64       // fraction (0-1) of the delay that is randomized
+}
+
+var (
+	// copying over binaries may fail for a while if the OS still holds a lock on them
+	copyRetryPolicy = retryPolicy{attempts: 6, delay: time.Second, maxDelay: 5 * time.Second, multiplier: 1.5, jitter: 0.2}
+
+	downloadRetryPolicy = retryPolicy{attempts: 4, delay: 2 * time.Second, maxDelay: 30 * time.Second, multiplier: 2, jitter: 0.3}
+
+	removeRetryPolicy = retryPolicy{attempts: 3, delay: 500 * time.Millisecond, maxDelay: 2 * time.Second, multiplier: 2, jitter: 0.2}
+)
+
+// retryError aggregates the errors of every failed attempt of an operation
+type retryError struct {
+	op   string
+	errs []error
+}
+
+func (e *retryError) Error() string {
+	msgs := make([]string, len(e.errs))
+	for n, err := range e.errs {
+		msgs[n] = fmt.Sprintf("attempt %d: %v", n+1, err)
+	}
+	return fmt.Sprintf("%s failed after %d attempt(s): %s", e.op, len(e.errs), strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the error of the last attempt
+func (e *retryError) Unwrap() error {
+	if len(e.errs) == 0 {
+		return nil
+	}
+	return e.errs[len(e.errs)-1]
+}
+
+// permanentError marks an error that retrying won't fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// permanent wraps err so that retry gives up immediately
+func permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// retry runs fn until it succeeds, returns a permanent error, the attempts are exhausted or ctx
+// is cancelled. op describes the operation in logs and in the returned error.
+func (p retryPolicy) retry(ctx context.Context, op string, fn func() error) error {
+	attempts := p.attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	rerr := &retryError{op: op}
+	delay := p.delay
+
+	for n := 1; n <= attempts; n++ {
+		if err := ctx.Err(); err != nil {
+			rerr.errs = append(rerr.errs, err)
+			break
+		}
+
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		rerr.errs = append(rerr.errs, err)
+
+		if _, ok := err.(*permanentError); ok {
+			break
+		}
+		if n == attempts {
+			break
+		}
+
+		wait := p.withJitter(delay)
+		log.Warnf("%s failed (attempt %d/%d), retrying in %v: %v", op, n, attempts, wait, err)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			rerr.errs = append(rerr.errs, ctx.Err())
+			return rerr
+		}
+
+		delay = time.Duration(float64(delay) * p.multiplier)
+		if p.maxDelay > 0 && delay > p.maxDelay {
+			delay = p.maxDelay
+		}
+	}
+
+	return rerr
+}
+
+// withJitter randomizes the given delay by +/- jitter percent
+func (p retryPolicy) withJitter(delay time.Duration) time.Duration {
+	if p.jitter <= 0 || delay <= 0 {
+		return delay
+	}
+	spread := float64(delay) * p.jitter
+	return time.Duration(float64(delay) - spread + rand.Float64()*2*spread)
+}
@@ -0,0 +1,368 @@
+package install
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"unicode/utf16"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// scheduleName names the scheduled update check in systemd and Task Scheduler
+	scheduleName = "molly-update-check"
+
+	// launchdLabel identifies the scheduled update check in launchd
+	launchdLabel = "com.grvlle.molly-installer.update-check"
+
+	// taskName is the name of the scheduled update check in Task Scheduler
+	taskName = "Molly Wallet update check"
+
+	// scheduledInstallerName is the copy of the installer in the install folder the scheduled
+	// update checks run, the installer itself usually sits in a downloads folder
+	scheduledInstallerName = "molly_installer"
+)
+
+// Schedule describes a scheduled update check
+type Schedule struct {
+	Files []string `json:"files"` // the files written or removed
+	Next  string   `json:"next"`  // what is left to the user, if anything
+}
+
+// scheduledInstallerPath returns the path of the installer copy the scheduled update checks run
+func (i *Install) scheduledInstallerPath() string {
+	return filepath.Join(i.dagFolderPath, scheduledInstallerName+i.OSSpecificSettings.fileExt)
+}
+
+// updateCheckCommand copies the running installer into the install folder and returns the
+// command line of the scheduled update check running the copy. The network settings are passed
+// on, except the proxy credentials which are read from the environment.
+func (i *Install) updateCheckCommand(policy UpdatePolicy) ([]string, error) {
+	exe, err := executablePath()
+	if err != nil {
+		return nil, fmt.Errorf("unable to locate the installer executable: %v", err)
+	}
+	installer := i.scheduledInstallerPath()
+	if filepath.Clean(exe) != filepath.Clean(installer) {
+		err = os.MkdirAll(i.dagFolderPath, 0744)
+		if err != nil {
+			return nil, err
+		}
+		err = copyFile(exe, installer, executableFileMode)
+		if err != nil {
+			return nil, fmt.Errorf("unable to copy the installer to %s: %v", installer, err)
+		}
+	}
+
+	cmd := []string{installer, "-install-dir", i.dagFolderPath, "-min-tls", i.config.MinTLSVersion}
+	if i.config.ProxyURL != "" {
+		cmd = append(cmd, "-proxy", i.config.ProxyURL)
+	}
+	if i.config.CABundle != "" {
+		caBundle, err := filepath.Abs(i.config.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("unable to resolve the CA bundle path: %v", err)
+		}
+		cmd = append(cmd, "-ca-bundle", caBundle)
+	}
+	return append(cmd, "check-updates", "-policy", string(policy)), nil
+}
+
+// ScheduleUpdateChecks runs an update check with policy once a day. On linux it's installed
+// as a systemd user timer. On macOS a launchd agent and on windows a Task Scheduler task are
+// generated, Schedule.Next tells how to load them.
+func (i *Install) ScheduleUpdateChecks(policy UpdatePolicy) (*Schedule, error) {
+	cmd, err := i.updateCheckCommand(policy)
+	if err != nil {
+		return nil, err
+	}
+
+	switch runtime.GOOS {
+	case "linux":
+		return installSystemdTimer(cmd)
+	case "darwin":
+		return writeLaunchdAgent(cmd)
+	case "windows":
+		return writeScheduledTask(cmd)
+	}
+	return nil, fmt.Errorf("scheduled update checks are not supported on %s", runtime.GOOS)
+}
+
+// UnscheduleUpdateChecks removes the scheduled update check
+func (i *Install) UnscheduleUpdateChecks() (*Schedule, error) {
+	s := &Schedule{}
+	var files []string
+	switch runtime.GOOS {
+	case "linux":
+		if err := systemctl("disable", "--now", scheduleName+".timer"); err != nil {
+			log.Warnf("Unable to disable the update check timer: %v", err)
+		}
+		dir, err := systemdUserDir()
+		if err != nil {
+			return nil, err
+		}
+		files = []string{filepath.Join(dir, scheduleName+".timer"), filepath.Join(dir, scheduleName+".service")}
+	case "darwin":
+		plist, err := launchdAgentPath()
+		if err != nil {
+			return nil, err
+		}
+		files = []string{plist}
+		s.Next = fmt.Sprintf("Unload the running agent with: launchctl unload %q", plist)
+	case "windows":
+		task, err := scheduledTaskPath()
+		if err != nil {
+			return nil, err
+		}
+		files = []string{task}
+		s.Next = fmt.Sprintf("Remove the registered task with: schtasks /Delete /TN %q /F", taskName)
+	default:
+		return nil, fmt.Errorf("scheduled update checks are not supported on %s", runtime.GOOS)
+	}
+
+	for _, file := range files {
+		if !fileExists(file) {
+			continue
+		}
+		err := os.Remove(file)
+		if err != nil {
+			return s, err
+		}
+		s.Files = append(s.Files, file)
+	}
+	if runtime.GOOS == "linux" {
+		if err := systemctl("daemon-reload"); err != nil {
+			log.Warnf("Unable to reload the systemd user units: %v", err)
+		}
+	}
+	return s, nil
+}
+
+func systemdUserDir() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to locate the user config directory: %v", err)
+	}
+	return filepath.Join(configDir, "systemd", "user"), nil
+}
+
+// installSystemdTimer writes a oneshot service running cmd and a daily timer for it, then
+// enables the timer
+func installSystemdTimer(cmd []string) (*Schedule, error) {
+	dir, err := systemdUserDir()
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, len(cmd))
+	for n, arg := range cmd {
+		args[n] = systemdQuote(arg)
+	}
+
+	service := fmt.Sprintf(`[Unit]
+Description=Check for Molly Wallet updates
+
+[Service]
+Type=oneshot
+ExecStart=%s
+`, strings.Join(args, " "))
+
+	timer := fmt.Sprintf(`[Unit]
+Description=Check for Molly Wallet updates daily
+
+[Timer]
+OnCalendar=daily
+RandomizedDelaySec=1h
+Persistent=true
+Unit=%s.service
+
+[Install]
+WantedBy=timers.target
+`, scheduleName)
+
+	s := &Schedule{}
+	files := map[string]string{
+		filepath.Join(dir, scheduleName+".service"): service,
+		filepath.Join(dir, scheduleName+".timer"):   timer,
+	}
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range sortedKeys(files) {
+		err = ioutil.WriteFile(file, []byte(files[file]), dataFileMode)
+		if err != nil {
+			return nil, err
+		}
+		s.Files = append(s.Files, file)
+	}
+
+	err = systemctl("daemon-reload")
+	if err == nil {
+		err = systemctl("enable", "--now", scheduleName+".timer")
+	}
+	if err != nil {
+		s.Next = fmt.Sprintf("Enable the timer with: systemctl --user enable --now %s.timer", scheduleName)
+		return s, fmt.Errorf("unable to enable the update check timer: %v", err)
+	}
+	return s, nil
+}
+
+func systemctl(args ...string) error {
+	out, err := exec.Command("systemctl", append([]string{"--user"}, args...)...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// systemdQuote quotes arg for ExecStart, % starts a specifier in unit files
+func systemdQuote(arg string) string {
+	arg = strings.ReplaceAll(arg, "%", "%%")
+	if !strings.ContainsAny(arg, " \t\"'\\") {
+		return arg
+	}
+	arg = strings.ReplaceAll(arg, `\`, `\\`)
+	return `"` + strings.ReplaceAll(arg, `"`, `\"`) + `"`
+}
+
+func launchdAgentPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to locate users home directory: %v", err)
+	}
+	return filepath.Join(homeDir, "Library", "LaunchAgents", launchdLabel+".plist"), nil
+}
+
+// writeLaunchdAgent writes a launchd agent running cmd once a day
+func writeLaunchdAgent(cmd []string) (*Schedule, error) {
+	plist, err := launchdAgentPath()
+	if err != nil {
+		return nil, err
+	}
+
+	var args strings.Builder
+	for _, arg := range cmd {
+		fmt.Fprintf(&args, "\t\t<string>%s</string>\n", xmlEscape(arg))
+	}
+	agent := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>%s</string>
+	<key>ProgramArguments</key>
+	<array>
+%s	</array>
+	<key>StartInterval</key>
+	<integer>86400</integer>
+	<key>RunAtLoad</key>
+	<false/>
+</dict>
+</plist>
+`, launchdLabel, args.String())
+
+	err = os.MkdirAll(filepath.Dir(plist), 0755)
+	if err != nil {
+		return nil, err
+	}
+	err = ioutil.WriteFile(plist, []byte(agent), dataFileMode)
+	if err != nil {
+		return nil, err
+	}
+	return &Schedule{
+		Files: []string{plist},
+		Next:  fmt.Sprintf("Load the agent with: launchctl load -w %q", plist),
+	}, nil
+}
+
+// scheduledTaskPath returns the path of the task file, it's kept out of the install folder
+// which is replaced by every installation
+func scheduledTaskPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to locate the user config directory: %v", err)
+	}
+	return filepath.Join(configDir, "molly_installer", scheduleName+".xml"), nil
+}
+
+// writeScheduledTask writes a Task Scheduler task running cmd once a day
+func writeScheduledTask(cmd []string) (*Schedule, error) {
+	var args []string
+	for _, arg := range cmd[1:] {
+		if strings.ContainsAny(arg, " \t\"") {
+			arg = `"` + strings.ReplaceAll(arg, `"`, `\"`) + `"`
+		}
+		args = append(args, arg)
+	}
+
+	task := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-16"?>
+<Task version="1.2" xmlns="http://schemas.microsoft.com/windows/2004/02/mit/task">
+  <RegistrationInfo>
+    <Description>Checks for Molly Wallet updates</Description>
+  </RegistrationInfo>
+  <Triggers>
+    <CalendarTrigger>
+      <StartBoundary>2020-01-01T12:00:00</StartBoundary>
+      <RandomDelay>PT1H</RandomDelay>
+      <ScheduleByDay>
+        <DaysInterval>1</DaysInterval>
+      </ScheduleByDay>
+    </CalendarTrigger>
+  </Triggers>
+  <Principals>
+    <Principal>
+      <LogonType>InteractiveToken</LogonType>
+      <RunLevel>LeastPrivilege</RunLevel>
+    </Principal>
+  </Principals>
+  <Settings>
+    <StartWhenAvailable>true</StartWhenAvailable>
+    <RunOnlyIfNetworkAvailable>true</RunOnlyIfNetworkAvailable>
+    <DisallowStartIfOnBatteries>false</DisallowStartIfOnBatteries>
+    <ExecutionTimeLimit>PT1H</ExecutionTimeLimit>
+  </Settings>
+  <Actions>
+    <Exec>
+      <Command>%s</Command>
+      <Arguments>%s</Arguments>
+    </Exec>
+  </Actions>
+</Task>
+`, xmlEscape(cmd[0]), xmlEscape(strings.Join(args, " ")))
+
+	// schtasks only reads UTF-16 task files
+	var buf bytes.Buffer
+	buf.Write([]byte{0xff, 0xfe})
+	binary.Write(&buf, binary.LittleEndian, utf16.Encode([]rune(strings.ReplaceAll(task, "\n", "\r\n"))))
+
+	file, err := scheduledTaskPath()
+	if err != nil {
+		return nil, err
+	}
+	err = os.MkdirAll(filepath.Dir(file), 0744)
+	if err != nil {
+		return nil, err
+	}
+	err = ioutil.WriteFile(file, buf.Bytes(), dataFileMode)
+	if err != nil {
+		return nil, err
+	}
+	return &Schedule{
+		Files: []string{file},
+		Next:  fmt.Sprintf("Register the task with: schtasks /Create /TN %q /XML %q", taskName, file),
+	}, nil
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,171 @@
+package install
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver"
+	log "github.com/sirupsen/logrus"
+)
+
+// installerReleasesAPIURL is the release feed of the installer itself
+const installerReleasesAPIURL = "https://api.github.com/repos/grvlle/molly_installer/releases"
+
+// selfUpdateTimeout bounds the whole self-update, so a slow network delays the start of the
+// installer by at most this long
+const selfUpdateTimeout = 20 * time.Second
+
+// relaunchedEnv is set for the relaunched installer, so it doesn't try to update itself again
+const relaunchedEnv = "MOLLY_INSTALLER_RELAUNCHED"
+
+// installerAssetName returns the name of the installer build for goos/goarch in the releases of
+// the installer, e.g molly_installer-linux-amd64 or molly_installer-windows-amd64.exe. Every
+// build is published with a checksum named after it, e.g molly_installer-linux-amd64.sha256.
+func installerAssetName(goos, goarch string) string {
+	name := "molly_installer-" + goos + "-" + goarch
+	if goos == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// Relaunched reports whether this installer was started by a self-update
+func Relaunched() bool {
+	return os.Getenv(relaunchedEnv) != ""
+}
+
+// SelfUpdate checks the release feed of the installer for a newer build. If there is one, it's
+// downloaded next to the running executable, verified against its checksum and swapped in
+// atomically. Reports whether the installer was replaced, it should be relaunched then.
+//
+// The checksum comes from the same release as the build, so it proves the download is intact,
+// not who published it. Releases aren't signed yet, which is why self-updates are opt-in.
+func (i *Install) SelfUpdate(ctx context.Context) (bool, error) {
+	err := i.begin("self-update")
+	if err != nil {
+		return false, err
+	}
+	defer i.end()
+
+	ctx, cancel := context.WithTimeout(ctx, selfUpdateTimeout)
+	defer cancel()
+
+	exe, err := executablePath()
+	if err != nil {
+		return false, fmt.Errorf("unable to locate the installer executable: %v", err)
+	}
+	removeReplacedExecutable(exe)
+
+	current, err := semver.NewVersion(Version)
+	if err != nil {
+		return false, fmt.Errorf("the installer version %s is invalid: %v", Version, err)
+	}
+
+	feed := *i.github
+	feed.baseURL = installerReleasesAPIURL
+	latest, err := feed.latestRelease(ctx)
+	if err != nil {
+		return false, fmt.Errorf("unable to check for installer updates: %v", err)
+	}
+	version, err := semver.NewVersion(strings.TrimPrefix(latest.TagName, "v"))
+	if err != nil {
+		return false, fmt.Errorf("installer release tag %s has no valid version: %v", latest.TagName, err)
+	}
+	if !version.GreaterThan(current) {
+		log.Infof("The installer is up to date (%s)", Version)
+		return false, nil
+	}
+
+	name := installerAssetName(runtime.GOOS, runtime.GOARCH)
+	asset, checksum := latest.asset(name), latest.asset(name+".sha256")
+	if asset == nil || checksum == nil {
+		return false, fmt.Errorf("installer release %s has no build with a checksum for %s/%s", latest.TagName, runtime.GOOS, runtime.GOARCH)
+	}
+	log.Infof("Updating the installer from %s to %s", Version, version)
+
+	newExe := exe + ".new"
+	checksumPath := newExe + ".sha256"
+	defer os.Remove(newExe) // no-op once swapped in
+	defer os.Remove(checksumPath)
+
+	err = downloadFile(ctx, i.client, checksum.BrowserDownloadURL, checksumPath, nil)
+	if err != nil {
+		return false, fmt.Errorf("unable to download the installer checksum: %v", err)
+	}
+	err = downloadFile(ctx, i.client, asset.BrowserDownloadURL, newExe, nil)
+	if err != nil {
+		return false, fmt.Errorf("unable to download the installer: %v", err)
+	}
+
+	expected, err := readChecksum(checksumPath)
+	if err != nil {
+		return false, fmt.Errorf("unable to read the installer checksum: %v", err)
+	}
+	actual, err := fileSHA256(newExe)
+	if err != nil {
+		return false, err
+	}
+	if actual != expected {
+		return false, fmt.Errorf("the checksum of the downloaded installer does not match the release checksum")
+	}
+
+	err = os.Chmod(newExe, executableFileMode)
+	if err != nil {
+		return false, err
+	}
+	err = replaceExecutable(exe, newExe)
+	if err != nil {
+		return false, fmt.Errorf("unable to replace the installer: %v", err)
+	}
+	log.Infof("Installer updated to %s", version)
+	return true, nil
+}
+
+// executablePath returns the path of the running executable with symlinks resolved, so the
+// executable itself is replaced rather than a link to it
+func executablePath() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(exe)
+}
+
+// replaceExecutable moves newExe over exe. The rename is atomic, so exe is either the old or the
+// new installer, never a mix. A running executable can't be overwritten on windows, but it can
+// be renamed, so it's moved aside first and removed by the next self-update.
+func replaceExecutable(exe, newExe string) error {
+	if runtime.GOOS != "windows" {
+		return os.Rename(newExe, exe)
+	}
+	old := exe + ".old"
+	os.Remove(old)
+	err := os.Rename(exe, old)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(newExe, exe)
+	if err != nil {
+		os.Rename(old, exe)
+		return err
+	}
+	return nil
+}
+
+// removeReplacedExecutable removes the installer that was moved aside by the last self-update
+// on windows
+func removeReplacedExecutable(exe string) {
+	old := exe + ".old"
+	if !fileExists(old) {
+		return
+	}
+	err := os.Remove(old)
+	if err != nil {
+		log.Warnf("Unable to remove the replaced installer %s: %v", old, err)
+	}
+}
@@ -0,0 +1,79 @@
+package install
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// State is the phase of the installation
+type State string
+
+// The states of an installation. Idle, Done, Failed and RolledBack are final, the installer
+// moves through the others while the pipeline runs.
+const (
+	StateIdle        State = "Idle"
+	StateResolving   State = "Resolving"
+	StateDownloading State = "Downloading"
+	StateVerifying   State = "Verifying"
+	StateExtracting  State = "Extracting"
+	StateInstalling  State = "Installing"
+	StateLaunching   State = "Launching"
+	StateDone        State = "Done"
+	StateFailed      State = "Failed"
+	StateRolledBack  State = "RolledBack"
+)
+
+// running reports whether an installation is in progress in state s
+func (s State) running() bool {
+	switch s {
+	case StateIdle, StateDone, StateFailed, StateRolledBack:
+		return false
+	}
+	return true
+}
+
+// InstallState is a snapshot of the installation, it lets the frontend rebuild its view
+// after a reload
+type InstallState struct {
+	State     State     `json:"state"`
+	Running   bool      `json:"running"`
+	Step      string    `json:"step"`
+	Progress  int       `json:"progress"`
+	Message   string    `json:"message"`
+	Error     string    `json:"error"`
+	Version   string    `json:"version"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// GetState returns the current state of the installation
+func (i *Install) GetState() InstallState {
+	i.mu.Lock()
+	s := i.state
+	i.mu.Unlock()
+
+	s.Running = s.State.running()
+	s.Progress, s.Message = i.progress.snapshot()
+	return s
+}
+
+// setState moves the installation to state and emits a "state" event with the new snapshot.
+// step names the pipeline step the state belongs to, err is recorded for the failure states.
+func (i *Install) setState(state State, step string, err error) {
+	i.mu.Lock()
+	prev := i.state.State
+	i.state.State = state
+	i.state.Step = step
+	i.state.Version = i.version
+	i.state.UpdatedAt = time.Now()
+	i.state.Error = ""
+	if err != nil {
+		i.state.Error = err.Error()
+	}
+	i.mu.Unlock()
+
+	if prev != state {
+		log.Infof("Installer state %s -> %s", prev, state)
+	}
+	i.emit("state", i.GetState())
+}
@@ -0,0 +1,228 @@
+package install
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"runtime"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// installSteps returns the steps of a full install in the order they run. OS specific
+// steps are included for every platform and opt out through their skip condition.
+func (i *Install) installSteps() []Step {
+	return []Step{
+		&step{
+			name:        "preflight",
+			description: "Running preflight checks...",
+			failure:     "Preflight checks failed",
+			weight:      3,
+			state:       StateResolving,
+			run: func(ctx context.Context, i *Install) error {
+				return i.preflight(ctx)
+			},
+		},
+		&step{
+			name:        "java",
+			description: "Checking Java Installation...",
+			failure:     "Unable to install Java",
+			weight:      22,
+			state:       StateInstalling,
+			skip: func(i *Install) bool {
+				return runtime.GOOS != "windows" || i.options.java == JavaSkip
+			},
+			run: func(ctx context.Context, i *Install) error {
+				if javaInstalled() {
+					return nil
+				}
+				if i.options.java == JavaRequire {
+					return fmt.Errorf("java is not installed")
+				}
+				i.updateStatus("Java not found. Installing Java (This may take some time)...")
+				return installJava(ctx)
+			},
+		},
+		&step{
+			name:        "stop-running-apps",
+			description: "Checking for running wallet processes...",
+			failure:     "Unable to close Molly Wallet",
+			weight:      3,
+			state:       StateInstalling,
+			run: func(ctx context.Context, i *Install) error {
+				return i.stopRunningApps(true)
+			},
+		},
+		&step{
+			name:        "prepare-fs",
+			description: "Preparing filesystem...",
+			failure:     "Unable to prepare filesystem",
+			weight:      2,
+			state:       StateInstalling,
+			run: func(ctx context.Context, i *Install) error {
+				return i.PrepareFS()
+			},
+			rollback: func(i *Install) error {
+				return i.restorePrevious()
+			},
+		},
+		&step{
+			name:        "download",
+			description: "Downloading packages...",
+			failure:     "Unable to download Molly Wallet package",
+			weight:      51,
+			state:       StateDownloading,
+			run: func(ctx context.Context, i *Install) error {
+				return i.downloadRelease(ctx)
+			},
+			rollback: func(i *Install) error {
+				return removeFiles(i.dagFolderPath, append(packageFiles(), checksumFile, checksumFile+".tmp"))
+			},
+		},
+		&step{
+			name:        "verify-checksum",
+			description: "Verifying Checksum...",
+			failure:     "Checksum missmatch. Corrupted download",
+			weight:      9,
+			state:       StateVerifying,
+			run: func(ctx context.Context, i *Install) error {
+				ok, err := i.VerifyChecksum(ctx, i.archivePath)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					return fmt.Errorf("the checksum of %s does not match the release checksum", path.Base(i.archivePath))
+				}
+				// only verified packages are cached
+				i.cache.store("", i.archivePath)
+				return nil
+			},
+		},
+		&step{
+			name:        "extract",
+			description: "Exctracting contents...",
+			failure:     "Unable to extract contents",
+			weight:      3,
+			state:       StateExtracting,
+			run: func(ctx context.Context, i *Install) error {
+				contents, err := unpackArchive(i.archivePath, i.tmpFolderPath)
+				if err != nil {
+					return err
+				}
+				i.contents = contents
+				return nil
+			},
+			rollback: func(i *Install) error {
+				return os.RemoveAll(path.Join(i.tmpFolderPath, "new_build"))
+			},
+		},
+		&step{
+			name:        "copy-binaries",
+			description: "Copy binaries...",
+			failure:     "Unable to overwrite old installation",
+			weight:      3,
+			state:       StateInstalling,
+			run: func(ctx context.Context, i *Install) error {
+				err := i.CopyAppBinaries(ctx, i.contents)
+				if err != nil {
+					return err
+				}
+				// updates replace the manifest of the previous installation
+				err = i.backupFile(i.manifestPath())
+				if err != nil {
+					return fmt.Errorf("unable to back up %s: %v", i.manifestPath(), err)
+				}
+				return i.writeManifest(&installManifest{
+					Version:     i.version,
+					OSBuild:     i.OSSpecificSettings.osBuild,
+					Arch:        i.build.arch,
+					Channel:     Channel(i.GetChannel()),
+					Package:     path.Base(i.archivePath),
+					InstalledAt: time.Now(),
+					RunID:       RunID(),
+					Files:       i.installedFiles(),
+				})
+			},
+			rollback: func(i *Install) error {
+				os.Remove(i.manifestPath())
+				return i.restoreBackups()
+			},
+		},
+		&step{
+			name:        "launch",
+			description: "Installation Complete! Launching Molly Wallet...",
+			weight:      2,
+			state:       StateLaunching,
+			skip: func(i *Install) bool {
+				return !i.options.launch
+			},
+			run: func(ctx context.Context, i *Install) error {
+				err := i.LaunchAppBinary()
+				if err != nil {
+					i.sendWarningNotification(codeLaunch, "Unable to start up Molly after Install", fmt.Sprintf("%v", err))
+					log.Errorf("Unable to start up Molly after Install: %v", err)
+				}
+				return nil
+			},
+		},
+		&step{
+			name:        "cleanup",
+			description: "Cleaning up...",
+			weight:      2,
+			state:       StateLaunching,
+			run: func(ctx context.Context, i *Install) error {
+				err := i.removePrevious()
+				if err == nil {
+					err = i.CleanUp()
+				}
+				if err != nil {
+					i.sendWarningNotification(codeCleanUp, "Unable to clear previous local state", fmt.Sprintf("%v", err))
+					log.Errorf("Unable to clear previous local state: %v", err)
+				}
+				return nil
+			},
+		},
+	}
+}
+
+// updateSteps returns the steps applying a package downloaded beforehand, see ApplyUpdate. They
+// are the steps of a full install that neither download anything nor empty the .dag folder.
+func (i *Install) updateSteps(launch bool) []Step {
+	var steps []Step
+	for _, s := range i.installSteps() {
+		switch s.Name() {
+		case "prepare-fs":
+			steps = append(steps, &step{
+				name:        "prepare-tmp",
+				description: "Preparing update...",
+				failure:     "Unable to prepare filesystem",
+				weight:      2,
+				state:       StateInstalling,
+				run: func(ctx context.Context, i *Install) error {
+					return i.makeTmpFolder()
+				},
+			})
+		case "stop-running-apps":
+			// the update binary of the wallet started the update, stopping it would stop the caller
+			steps = append(steps, &step{
+				name:        s.Name(),
+				description: s.Description(),
+				failure:     "Unable to close Molly Wallet",
+				weight:      s.Weight(),
+				state:       s.State(),
+				run: func(ctx context.Context, i *Install) error {
+					return i.stopRunningApps(false)
+				},
+			})
+		case "launch":
+			if launch {
+				steps = append(steps, s)
+			}
+		case "verify-checksum", "extract", "copy-binaries", "cleanup":
+			steps = append(steps, s)
+		}
+	}
+	return steps
+}
@@ -1,6 +1,7 @@
 package install
 
 import (
+	"os"
 	"path"
 	"regexp"
 	"runtime"
@@ -14,40 +15,82 @@ import (
 //   'Molly Wallet.lnk'   cl-wallet.jar   mollywallet.exe   tmp
 //   cl-keytool.jar      install.log     store.db          wallet.log
 //   update.exe
-// And also removing the shortcuts on Windows.
+// And also removing the shortcuts on Windows. An install folder other than ~/.dag without an
+// install manifest is only removed if nothing but Molly Wallet files were in it.
 func (i *Install) Uninstall() {
 
-	updateBinary := "update" + i.OSSpecificSettings.fileExt
+	err := i.begin("uninstallation")
+	if err != nil {
+		log.Warnln(err)
+		i.sendErrorNotification(codeBusy, "Installer busy", convertErrorToString(err))
+		return
+	}
+	defer i.end()
+
+	lock, err := acquireLock(i.dagFolderPath)
+	if err != nil {
+		log.Errorf("Unable to lock the installation folder: %v", err)
+		i.sendErrorNotification(codeBusy, "Installer busy", convertErrorToString(err))
+		return
+	}
+	defer lock.release()
+
+	err = i.stopRunningApps(true)
+	if err != nil {
+		i.sendErrorNotification(codeStopApps, "Unable to close Molly Wallet", convertErrorToString(err))
+		log.Errorf("Unable to close Molly Wallet: %v", err)
+		return
+	}
 
-	files := make([]string, 13)
-	files = append(files, "update.log", updateBinary, "wallet.log", "store.db", "cl-keytool.jar.tmp", "cl-keytool.jar", "cl-wallet.jar", "cl-wallet.jar.tmp", "mollywallet.zip", "mollywallet.zip.tmp", "Molly Wallet.lnk", "mollywallet.exe")
+	// decided before the manifest is removed
+	owned := i.ownsInstallDir()
+
+	if _, err := i.UnscheduleUpdateChecks(); err != nil {
+		log.Warnf("Unable to remove the scheduled update checks: %v", err)
+	}
+
+	files := make([]string, 6)
+	files = append(files, "update.log", "wallet.log", "store.db", preferencesFileName, scheduledInstallerName+i.OSSpecificSettings.fileExt)
+	files = append(files, i.installArtifacts()...)
 
 	log.Infoln("Removing dependencies...")
-	err := removeFiles(i.dagFolderPath, files)
+	err = removeFiles(i.dagFolderPath, files)
 	if err != nil {
-		i.sendErrorNotification("Error:", convertErrorToString(err))
+		i.sendWarningNotification(codeRemoveFiles, "Error:", convertErrorToString(err))
 		log.Errorf("Error: %v", err)
 	}
 
+	// the lock file has to go before the .dag folder can be removed
+	lock.release()
+
 	folders := make([]string, 3)
-	folders = append(folders, path.Join(i.dagFolderPath, "tmp"), i.tmpFolderPath, i.dagFolderPath)
+	folders = append(folders, i.tmpFolderPath)
+	if owned {
+		folders = append(folders, path.Join(i.dagFolderPath, "tmp"), i.dagFolderPath)
+	}
 
 	err = removeFolders(folders)
 	if err != nil {
-		i.sendErrorNotification("Error:", convertErrorToString(err))
+		i.sendWarningNotification(codeRemoveFiles, "Error:", convertErrorToString(err))
 		log.Errorf("Error: %v", err)
 	}
+	// any other folder may hold files of the user, it's only removed if nothing else is left in it
+	if !owned && fileExists(i.dagFolderPath) {
+		if err := os.Remove(i.dagFolderPath); err != nil {
+			log.Infof("Keeping %s, it holds files that don't belong to Molly Wallet", i.dagFolderPath)
+		}
+	}
 
 	log.Infoln("Removing shortcuts on Windows...")
 	if runtime.GOOS == "windows" {
 		err := removeFile(i.OSSpecificSettings.startMenuPath, "Molly Wallet.lnk")
 		if err != nil {
-			i.sendErrorNotification("Unable to remove shortcut from start menu", convertErrorToString(err))
+			i.sendWarningNotification(codeRemoveShortcuts, "Unable to remove shortcut from start menu", convertErrorToString(err))
 			log.Errorf("Error: %v", err)
 		}
 		err = removeFile(i.OSSpecificSettings.desktopPath, "Molly Wallet.lnk")
 		if err != nil {
-			i.sendErrorNotification("Unable to remove shortcut from desktop", convertErrorToString(err))
+			i.sendWarningNotification(codeRemoveShortcuts, "Unable to remove shortcut from desktop", convertErrorToString(err))
 			log.Errorf("Error: %v", err)
 		}
 	}
@@ -68,7 +111,7 @@ func convertErrorToString(err error) string {
 	re := regexp.MustCompile("[" + r + "]+")
 	errString = re.ReplaceAllString(errString, "")
 
-	if len(errString) >= 15 {
+	if len(errString) > 55 {
 		bytes := []byte(errString)
 		return string(bytes[0:55])
 	}
@@ -0,0 +1,98 @@
+package install
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	log "github.com/sirupsen/logrus"
+)
+
+// UpdateOptions describe a package downloaded beforehand, e.g by the update binary of the wallet
+type UpdateOptions struct {
+	// Archive is the Molly Wallet package to install
+	Archive string
+	// Checksum is the checksum file of the package, checksum.sha256 next to the package by default
+	Checksum string
+	// Version is the version of the package, recorded in the install manifest. It's required,
+	// update checks compare the latest release with it.
+	Version string
+	// Launch starts Molly Wallet once the update is applied
+	Launch bool
+}
+
+// ApplyUpdate installs a package downloaded beforehand through the checksum, extraction, copy and
+// rollback steps of a full install, leaving the rest of the .dag folder in place. Nothing is
+// downloaded. The update binary in the .dag folder is replaced as well, so on windows it has to
+// start the update and exit instead of waiting for it.
+func (i *Install) ApplyUpdate(ctx context.Context, opts UpdateOptions) error {
+	version, err := semver.NewVersion(strings.TrimPrefix(opts.Version, "v"))
+	if err != nil {
+		return fmt.Errorf("the version %q of the package is invalid: %v", opts.Version, err)
+	}
+	archive, err := filepath.Abs(opts.Archive)
+	if err != nil {
+		return fmt.Errorf("unable to resolve the package path: %v", err)
+	}
+	if !fileExists(archive) {
+		return fmt.Errorf("the package %s does not exist", archive)
+	}
+	checksum := opts.Checksum
+	if checksum == "" {
+		checksum = filepath.Join(filepath.Dir(archive), checksumFile)
+	}
+	if !fileExists(checksum) {
+		return fmt.Errorf("the checksum %s does not exist, the package can't be verified without it", checksum)
+	}
+
+	err = i.begin("update")
+	if err != nil {
+		return err
+	}
+	defer i.end()
+	defer i.resetRelease()
+
+	lock, err := acquireLock(i.dagFolderPath)
+	if err != nil {
+		i.setState(StateFailed, "", err)
+		return fmt.Errorf("unable to lock the installation folder: %v", err)
+	}
+	defer lock.release()
+
+	ctx, cancel := context.WithCancel(ctx)
+	i.setCancel(cancel)
+	defer i.setCancel(nil)
+	defer cancel()
+
+	i.backups = nil
+	i.archivePath = archive
+	i.checksumPath = checksum
+	i.version = version.String()
+	i.setBuild(archFallback{arch: i.OSSpecificSettings.arch})
+	log.Infof("Applying the update %s (version %s)", archive, i.version)
+
+	i.progress.start()
+	defer i.progress.stop(-1, "")
+
+	err = i.runPipeline(ctx, i.updateSteps(opts.Launch))
+	if err != nil {
+		if cerr := i.CleanUp(); cerr != nil {
+			log.Errorf("Unable to clean up after the failed update: %v", cerr)
+		}
+		title := "Update failed"
+		if serr, ok := err.(*stepError); ok {
+			title = serr.title()
+		}
+		percent, _ := i.progress.snapshot()
+		i.progress.stop(percent, title)
+		i.sendErrorNotification(codeStepFailed, title, fmt.Sprintf("%v", err))
+		return fmt.Errorf("%s: %v", title, err)
+	}
+
+	i.progress.stop(100, "Update Complete!")
+	i.setState(StateDone, "", nil)
+	i.sendSuccessNotification("Success!", "Molly wallet has been successfully updated.")
+	return nil
+}
@@ -0,0 +1,159 @@
+package install
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// updateFixture is an installation of version 1.0.0 and the package of version 1.1.0
+type updateFixture struct {
+	installer *Install
+	dagDir    string
+	archive   string
+	checksum  string
+}
+
+func newUpdateFixture(t *testing.T) *updateFixture {
+	if runtime.GOOS != "linux" {
+		t.Skip("the update fixture installs into the real applications and start menu folders on " + runtime.GOOS)
+	}
+	dir := t.TempDir()
+	f := &updateFixture{
+		dagDir:   filepath.Join(dir, "dag"),
+		archive:  filepath.Join(dir, "mollywallet.zip"),
+		checksum: filepath.Join(dir, "checksum.sha256"),
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range map[string]string{"mollywallet": "mollywallet 1.1.0", "update": "update 1.1.0"} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, f.archive, buf.String())
+	sum := sha256.Sum256(buf.Bytes())
+	writeFile(t, f.checksum, hex.EncodeToString(sum[:])+"  mollywallet.zip\n")
+
+	i, err := Init(Config{InstallDir: f.dagDir, TmpDir: filepath.Join(dir, "tmp"), CacheDir: filepath.Join(dir, "cache")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.installer = i
+
+	writeFile(t, filepath.Join(f.dagDir, "mollywallet"), "mollywallet 1.0.0")
+	writeFile(t, filepath.Join(f.dagDir, "update"), "update 1.0.0")
+	writeFile(t, filepath.Join(f.dagDir, "store.db"), "wallet data")
+	if err := i.writeManifest(&installManifest{Version: "1.0.0", OSBuild: "linux"}); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func (f *updateFixture) apply(ctx context.Context) error {
+	return f.installer.ApplyUpdate(ctx, UpdateOptions{Archive: f.archive, Checksum: f.checksum, Version: "1.1.0"})
+}
+
+// assertInstalled checks the installed binaries and the version in the manifest
+func (f *updateFixture) assertInstalled(t *testing.T, version string) {
+	t.Helper()
+	for _, name := range []string{"mollywallet", "update"} {
+		data, err := ioutil.ReadFile(filepath.Join(f.dagDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := name + " " + version; string(data) != want {
+			t.Errorf("%s = %q, want %q", name, data, want)
+		}
+	}
+	m, err := f.installer.readManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Version != version {
+		t.Errorf("manifest version = %s, want %s", m.Version, version)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(f.dagDir, "store.db"))
+	if err != nil || string(data) != "wallet data" {
+		t.Errorf("store.db = %q, %v, the update must leave the wallet data alone", data, err)
+	}
+}
+
+func TestApplyUpdate(t *testing.T) {
+	f := newUpdateFixture(t)
+
+	err := f.apply(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.assertInstalled(t, "1.1.0")
+	if state := f.installer.GetState(); state.State != StateDone {
+		t.Errorf("state = %s, want %s", state.State, StateDone)
+	}
+}
+
+func TestApplyUpdateChecksumMismatch(t *testing.T) {
+	f := newUpdateFixture(t)
+	writeFile(t, f.checksum, strings.Repeat("0", 64)+"\n")
+
+	err := f.apply(context.Background())
+	if err == nil {
+		t.Fatal("the update succeeded with a wrong checksum")
+	}
+	f.assertInstalled(t, "1.0.0")
+}
+
+func TestApplyUpdateRollback(t *testing.T) {
+	f := newUpdateFixture(t)
+
+	// cancel once the binaries are copied, so the copy has to be rolled back
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	f.installer.SetEventWriter(&cancelAfterStep{step: "copy-binaries", cancel: cancel}, true)
+
+	err := f.apply(ctx)
+	if err == nil {
+		t.Fatal("the cancelled update succeeded")
+	}
+	f.assertInstalled(t, "1.0.0")
+	if state := f.installer.GetState(); state.State != StateRolledBack {
+		t.Errorf("state = %s, want %s", state.State, StateRolledBack)
+	}
+}
+
+// cancelAfterStep is an event writer calling cancel once step ended
+type cancelAfterStep struct {
+	step   string
+	cancel context.CancelFunc
+}
+
+func (w *cancelAfterStep) Write(b []byte) (int, error) {
+	if bytes.Contains(b, []byte(`"event":"step-end"`)) && bytes.Contains(b, []byte(`"step":"`+w.step+`"`)) {
+		w.cancel()
+	}
+	return len(b), nil
+}
+
+func writeFile(t *testing.T, file, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
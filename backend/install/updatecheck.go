@@ -0,0 +1,164 @@
+package install
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver"
+	log "github.com/sirupsen/logrus"
+)
+
+// UpdatePolicy decides what an update check does when a newer release is available
+type UpdatePolicy string
+
+// The update policies
+const (
+	PolicyNotify UpdatePolicy = "notify" // show a desktop notification
+	PolicyAuto   UpdatePolicy = "auto"   // install the newer release
+)
+
+// ParseUpdatePolicy returns the policy called name
+func ParseUpdatePolicy(name string) (UpdatePolicy, error) {
+	switch p := UpdatePolicy(strings.ToLower(strings.TrimSpace(name))); p {
+	case PolicyNotify, PolicyAuto:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown update policy %q, expected notify or auto", name)
+}
+
+// UpdateCheck is the result of comparing the installed version with the release feed
+type UpdateCheck struct {
+	Installed string `json:"installed"`
+	Latest    string `json:"latest"`
+	Channel   string `json:"channel"`
+	Available bool   `json:"available"`
+}
+
+// Summary describes the result for the command line
+func (c *UpdateCheck) Summary() string {
+	if !c.Available {
+		return fmt.Sprintf("Molly Wallet %s is up to date on the %s channel", c.Installed, c.Channel)
+	}
+	return fmt.Sprintf("Molly Wallet %s is available on the %s channel, %s is installed", c.Latest, c.Channel, c.Installed)
+}
+
+// CheckForUpdates compares the version in the install manifest with the latest release on the
+// remembered channel. Like GetReleaseInfo, it keeps the release for the next installation.
+func (i *Install) CheckForUpdates(ctx context.Context) (*UpdateCheck, error) {
+	m, err := i.readManifest()
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("Molly Wallet is not installed in %s", i.dagFolderPath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the install manifest: %v", err)
+	}
+	installed, err := semver.NewVersion(m.Version)
+	if err != nil {
+		return nil, fmt.Errorf("the installed version %q is unknown: %v", m.Version, err)
+	}
+
+	err = i.begin("update check")
+	if err != nil {
+		return nil, err
+	}
+	defer i.end()
+
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	err = i.resolveRelease(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to look up the latest release: %v", err)
+	}
+	latest, err := semver.NewVersion(i.version)
+	if err != nil {
+		return nil, err
+	}
+
+	check := &UpdateCheck{
+		Installed: m.Version,
+		Latest:    i.version,
+		Channel:   i.GetChannel(),
+		Available: latest.GreaterThan(installed),
+	}
+	log.Infof("Update check: %s", check.Summary())
+	return check, nil
+}
+
+// RunUpdateCheck checks for updates and applies policy if one is available. It's what the
+// scheduled update checks run, see ScheduleUpdateChecks.
+func (i *Install) RunUpdateCheck(ctx context.Context, policy UpdatePolicy) (*UpdateCheck, error) {
+	check, err := i.CheckForUpdates(ctx)
+	if err != nil || !check.Available {
+		return check, err
+	}
+
+	switch policy {
+	case PolicyAuto:
+		log.Infof("Installing Molly Wallet %s", check.Latest)
+		// scheduled updates run unattended, the wallet isn't launched once they're done
+		i.mu.Lock()
+		options := i.options
+		i.options.launch = false
+		i.mu.Unlock()
+		i.Run(ctx)
+		i.mu.Lock()
+		i.options = options
+		i.mu.Unlock()
+		if state := i.GetState(); state.State != StateDone {
+			return check, fmt.Errorf("the update ended in state %s: %s", state.State, state.Error)
+		}
+	default:
+		err = desktopNotification("Molly Wallet update available",
+			fmt.Sprintf("Molly Wallet %s is available, you have %s. Run the Molly Wallet installer to update.", check.Latest, check.Installed))
+		if err != nil {
+			log.Warnf("Unable to show the update notification: %v", err)
+		}
+	}
+	return check, nil
+}
+
+// desktopNotification shows a notification through the notification service of the desktop
+func desktopNotification(title, msg string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "linux":
+		cmd = exec.Command("notify-send", "--app-name=Molly Wallet", title, msg)
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s", appleScriptString(msg), appleScriptString(title))
+		cmd = exec.Command("osascript", "-e", script)
+	case "windows":
+		script := fmt.Sprintf(`Add-Type -AssemblyName System.Windows.Forms, System.Drawing
+$n = New-Object System.Windows.Forms.NotifyIcon
+$n.Icon = [System.Drawing.SystemIcons]::Information
+$n.BalloonTipTitle = %s
+$n.BalloonTipText = %s
+$n.Visible = $true
+$n.ShowBalloonTip(10000)
+Start-Sleep -Seconds 10
+$n.Dispose()`, powerShellString(title), powerShellString(msg))
+		cmd = exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", script)
+	default:
+		return fmt.Errorf("desktop notifications are not supported on %s", runtime.GOOS)
+	}
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func appleScriptString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+func powerShellString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -1,60 +1,26 @@
 package install
 
 import (
-	"encoding/json"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"path"
+	"path/filepath"
 	"runtime"
 
-	"github.com/artdarek/go-unzip"
 	log "github.com/sirupsen/logrus"
 )
 
-// getLatestRelease polls the github api for the latest release in the constellation_wallet repo
-// and returns the sem ver and error
-func (i *Install) getLatestRelease() (string, error) {
-
-	const (
-		url = "https://api.github.com/repos/grvlle/constellation_wallet/releases/latest"
-	)
-
-	resp, err := http.Get(url)
-	if err != nil {
-		return "", fmt.Errorf("failed to send HTTP request: %v", err)
-	}
-	if resp == nil {
-		return "", fmt.Errorf("empty response from Github API: %v", err)
-	}
-	defer resp.Body.Close()
-
-	bodyBytes, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-
-		return "", fmt.Errorf("unable to parse GitHub API response: %v", err)
-	}
-
-	var result map[string]interface{}
-
-	// Unmarshal or Decode the JSON to the interface.
-	err = json.Unmarshal(bodyBytes, &result)
-	if err != nil {
-		return "", err
-	}
-
-	release := result["tag_name"]
-	bytes := []byte(release.(string))
-	version := string(bytes[1:6])
-	return version, err
-
-}
-
 func removeFile(filePath string, file string) error {
 	if fileExists(path.Join(filePath, file)) && file != "" {
-		err := os.Remove(path.Join(filePath, file))
+		err := removeRetryPolicy.retry(context.Background(), "removing "+file, func() error {
+			return os.Remove(path.Join(filePath, file))
+		})
 		if err != nil {
 			return err
 		}
@@ -64,12 +30,9 @@ func removeFile(filePath string, file string) error {
 
 func removeFiles(filePath string, files []string) error {
 	for _, file := range files {
-		if fileExists(path.Join(filePath, file)) && file != "" {
-			err := os.Remove(path.Join(filePath, file))
-			if err != nil {
-				return err
-			}
-
+		err := removeFile(filePath, file)
+		if err != nil {
+			return err
 		}
 	}
 	return nil
@@ -78,32 +41,58 @@ func removeFiles(filePath string, files []string) error {
 func removeFolders(folders []string) error {
 	for _, folder := range folders {
 		if fileExists(folder) && folder != "" {
-			if fileExists(folder) {
-				err := os.RemoveAll(folder)
-				if err != nil {
-					return err
-				}
+			err := removeRetryPolicy.retry(context.Background(), "removing "+folder, func() error {
+				return os.RemoveAll(folder)
+			})
+			if err != nil {
+				return err
 			}
 		}
 	}
 	return nil
 }
 
-func downloadFile(url, filePath string) error {
+// downloadFile downloads url to filePath, retrying on failures until ctx is cancelled. progress,
+// if not nil, is called with the bytes written by the current attempt and the size of the file,
+// -1 if unknown.
+func downloadFile(ctx context.Context, client *http.Client, url, filePath string, progress func(written, size int64)) error {
+	return downloadRetryPolicy.retry(ctx, "downloading "+url, func() error {
+		return downloadFileOnce(ctx, client, url, filePath, progress)
+	})
+}
+
+func downloadFileOnce(ctx context.Context, client *http.Client, url, filePath string, progress func(written, size int64)) error {
 
 	tmpFilePath := filePath + ".tmp"
 	out, err := os.Create(tmpFilePath)
 	if err != nil {
-		return err
+		return permanent(err)
 	}
+	defer out.Close()
 
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return permanent(err)
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
-	if _, err = io.Copy(out, resp.Body); err != nil {
+	if resp.StatusCode == http.StatusNotFound {
+		return permanent(fmt.Errorf("%s not found", url))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected HTTP status: %s", resp.Status)
+	}
+
+	var w io.Writer = out
+	if progress != nil {
+		progress(0, resp.ContentLength)
+		w = &progressWriter{w: out, size: resp.ContentLength, report: progress}
+	}
+	if _, err = io.Copy(w, resp.Body); err != nil {
 		return err
 	}
 
@@ -115,13 +104,34 @@ func downloadFile(url, filePath string) error {
 	return nil
 }
 
-func getDefaultDagFolderPath() string {
-	userDir, err := os.UserHomeDir()
+// progressWriter reports the bytes written through it
+type progressWriter struct {
+	w       io.Writer
+	written int64
+	size    int64
+	report  func(written, size int64)
+}
+
+func (pw *progressWriter) Write(b []byte) (int, error) {
+	n, err := pw.w.Write(b)
+	pw.written += int64(n)
+	pw.report(pw.written, pw.size)
+	return n, err
+}
+
+// fileSHA256 returns the hex encoded SHA-256 of the file at filePath
+func fileSHA256(filePath string) (string, error) {
+	f, err := os.Open(filePath)
 	if err != nil {
-		log.Errorf("Unable to detect UserHomeDir: %v", err)
-		return ""
+		return "", err
+	}
+	defer f.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, f); err != nil {
+		return "", err
 	}
-	return userDir + "/.dag"
+	return hex.EncodeToString(hasher.Sum(nil)), nil
 }
 
 func fileExists(path string) bool {
@@ -132,45 +142,109 @@ func fileExists(path string) bool {
 	return !os.IsNotExist(err)
 }
 
-func copyFile(src string, dst string) error {
-	// Read all content of src to data
-	data, err := ioutil.ReadFile(src)
+// file modes for the different kinds of installed artifacts
+const (
+	executableFileMode os.FileMode = 0755
+	dataFileMode       os.FileMode = 0644
+	preserveFileMode   os.FileMode = 0 // keep the mode of the source file
+)
+
+// copyFile streams src into a temporary file in the destination folder, syncs it to disk
+// and renames it into place. This way a crash never leaves a truncated dst behind.
+func copyFile(src string, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
 	if err != nil {
 		return err
 	}
+	defer in.Close()
 
-	// Write data to dst
-	err = ioutil.WriteFile(dst, data, 0755)
+	if mode == preserveFileMode {
+		info, err := in.Stat()
+		if err != nil {
+			return err
+		}
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(dst), "."+filepath.Base(dst)+".tmp")
 	if err != nil {
 		return err
 	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath) // no-op once renamed
+
+	if _, err = io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	// TempFile creates files with 0600, set the mode explicitly regardless of umask
+	if err = os.Chmod(tmpPath, mode); err != nil {
+		return err
+	}
+
+	if err = os.Rename(tmpPath, dst); err != nil {
+		return err
+	}
+
+	syncDir(filepath.Dir(dst))
 	return nil
 }
 
-// Unzips archive to dstPath, returns path to wallet binary
-func unzipArchive(zippedArchive, dstPath string) (*unzippedContents, error) {
+// syncDir flushes a directory entry to disk after a rename. Not supported on Windows.
+func syncDir(dir string) {
+	if runtime.GOOS == "windows" {
+		return
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	defer d.Close()
+	d.Sync()
+}
+
+// unpackArchive extracts the package to dstPath, returns the paths to the extracted binaries
+func unpackArchive(archive, dstPath string) (*packageContents, error) {
 
-	uz := unzip.New(zippedArchive, path.Join(dstPath, "new_build"))
-	err := uz.Extract()
+	buildPath := path.Join(dstPath, "new_build")
+	report, err := extractArchive(archive, buildPath, defaultExtractLimits)
 	if err != nil {
 		return nil, err
 	}
+	log.Infof("Extracted %s to %s: %v", archive, buildPath, report)
+
 	var fileExt string
 	if runtime.GOOS == "windows" {
 		fileExt = ".exe"
 	}
 
-	contents := &unzippedContents{
-		mollyBinaryPath:  path.Join(dstPath, "new_build", "mollywallet"+fileExt),
-		updateBinaryPath: path.Join(dstPath, "new_build", "update"+fileExt),
-		mollyMacOSApp:    path.Join(dstPath, "new_build", "MollyWallet.app"),
+	contents := &packageContents{
+		mollyBinaryPath:  path.Join(buildPath, "mollywallet"+fileExt),
+		updateBinaryPath: path.Join(buildPath, "update"+fileExt),
+		mollyMacOSApp:    path.Join(buildPath, "MollyWallet.app"),
+		report:           report,
+	}
+
+	if !fileExists(contents.mollyBinaryPath) {
+		return nil, fmt.Errorf("the package does not contain the mollywallet%s binary", fileExt)
+	}
+	if runtime.GOOS == "darwin" && !fileExists(contents.mollyMacOSApp) {
+		return nil, fmt.Errorf("the package does not contain MollyWallet.app")
 	}
 
-	return contents, err
+	return contents, nil
 }
 
-// getUserOS returns the users OS, the file extension of executables and path to put molly wallet binary for said OS
-func getOSSpecificSettings() *settings {
+// getUserOS returns the users OS and architecture, the file extension of executables and path to put molly wallet binary for said OS.
+// installDir is the folder Molly Wallet is installed to.
+func getOSSpecificSettings(installDir string) *settings {
 
 	s := &settings{}
 
@@ -185,7 +259,7 @@ func getOSSpecificSettings() *settings {
 		s = &settings{
 			osBuild:      "darwin",
 			fileExt:      "",
-			binaryPath:   path.Join(getDefaultDagFolderPath(), "mollywallet"),
+			binaryPath:   path.Join(installDir, "mollywallet"),
 			shortcutPath: path.Join(homeDir, "Applications", "MollyWallet.app"),
 		}
 
@@ -193,27 +267,28 @@ func getOSSpecificSettings() *settings {
 		s = &settings{
 			osBuild:    "linux",
 			fileExt:    "",
-			binaryPath: path.Join("usr", "local", "bin", "mollywallet"),
+			binaryPath: path.Join(installDir, "mollywallet"),
 		}
 
 	case "windows":
 		s = &settings{
 			osBuild:       "windows",
 			fileExt:       ".exe",
-			binaryPath:    path.Join(getDefaultDagFolderPath(), "mollywallet.exe"),
+			binaryPath:    path.Join(installDir, "mollywallet.exe"),
 			startMenuPath: path.Join(homeDir, "AppData", "Roaming", "Microsoft", "Windows", "Start Menu", "Programs"),
 			desktopPath:   path.Join(homeDir, "Desktop"),
-			shortcutPath:  getDefaultDagFolderPath() + "/Molly Wallet.lnk",
+			shortcutPath:  path.Join(installDir, "Molly Wallet.lnk"),
 		}
 
 	default:
 		s = &settings{
 			osBuild:    "unsupported",
 			fileExt:    "",
-			binaryPath: getDefaultDagFolderPath(),
+			binaryPath: installDir,
 		}
 
 	}
+	s.arch = runtime.GOARCH
 
 	return s
 }
@@ -2,8 +2,10 @@ package install
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os/exec"
 	"runtime"
 	"strings"
@@ -45,9 +47,60 @@ func createWindowsShortcuts(src, dst string) error {
 	return nil
 }
 
-func installJava() error {
-	// local backend
-	back := &backend.Local{}
+// contextBackend is a go-powershell backend that starts PowerShell with exec.CommandContext,
+// so the process and whatever it's running are killed once ctx is cancelled.
+type contextBackend struct {
+	ctx context.Context
+}
+
+func (b *contextBackend) StartProcess(cmd string, args ...string) (backend.Waiter, io.Writer, io.Reader, io.Reader, error) {
+	command := exec.CommandContext(b.ctx, cmd, args...)
+
+	stdin, err := command.StdinPipe()
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("unable to get PowerShell stdin: %v", err)
+	}
+	stdout, err := command.StdoutPipe()
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("unable to get PowerShell stdout: %v", err)
+	}
+	stderr, err := command.StderrPipe()
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("unable to get PowerShell stderr: %v", err)
+	}
+
+	err = command.Start()
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("unable to start PowerShell: %v", err)
+	}
+	return command, stdin, stdout, stderr, nil
+}
+
+// executeCtx runs cmd in shell and returns early once ctx is cancelled. The backend kills the
+// PowerShell process at that point, which aborts the command.
+func executeCtx(ctx context.Context, shell ps.Shell, cmd string) (string, string, error) {
+	type result struct {
+		stdout, stderr string
+		err            error
+	}
+	done := make(chan result, 1)
+
+	go func() {
+		stdout, stderr, err := shell.Execute(cmd)
+		done <- result{stdout, stderr, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.stdout, r.stderr, r.err
+	case <-ctx.Done():
+		return "", "", ctx.Err()
+	}
+}
+
+func installJava(ctx context.Context) error {
+	// local backend, bound to ctx
+	back := &contextBackend{ctx: ctx}
 
 	// start a local powershell process
 	shell, err := ps.New(back)
@@ -57,7 +110,7 @@ func installJava() error {
 	defer shell.Exit()
 
 	// setting the execution policy to the current user
-	stdout, stderr, err := shell.Execute("Set-ExecutionPolicy RemoteSigned -scope CurrentUser")
+	stdout, stderr, err := executeCtx(ctx, shell, "Set-ExecutionPolicy RemoteSigned -scope CurrentUser")
 	if err != nil {
 		return fmt.Errorf("unable to set ExecutionPolicy to CurrentUser. %v", err)
 	}
@@ -67,7 +120,7 @@ func installJava() error {
 	log.Infof("setting ExecutionPolicy to CurrentUser. stdout: %s", stdout)
 
 	// installing scoop package manager for windows: https://github.com/lukesampson/scoop
-	stdout, stderr, err = shell.Execute("iwr -useb get.scoop.sh | iex")
+	stdout, stderr, err = executeCtx(ctx, shell, "iwr -useb get.scoop.sh | iex")
 	if err != nil {
 		return fmt.Errorf("unable to install scoop. %v", err)
 	}
@@ -77,7 +130,7 @@ func installJava() error {
 	log.Infof("installing scoop. stdout: %s", stdout)
 
 	// installing git as a dependancy
-	stdout, stderr, err = shell.Execute("scoop install git")
+	stdout, stderr, err = executeCtx(ctx, shell, "scoop install git")
 	if err != nil {
 		return fmt.Errorf("unable to install git using scoop. %v", err)
 	}
@@ -87,7 +140,7 @@ func installJava() error {
 	log.Infof("installing git using scoop. stdout: %s", stdout)
 
 	// adding java bucket to scoop https://github.com/lukesampson/scoop/wiki/Java
-	stdout, stderr, err = shell.Execute("scoop bucket add java")
+	stdout, stderr, err = executeCtx(ctx, shell, "scoop bucket add java")
 	if err != nil {
 		return fmt.Errorf("unable to add java bucket to scoop. %v", err)
 	}
@@ -97,7 +150,7 @@ func installJava() error {
 	log.Infof("adding java bucket to scoop. stdout: %s", stdout)
 
 	// installing adoptopenjdk-hotspot
-	stdout, stderr, err = shell.Execute("scoop install adoptopenjdk-hotspot")
+	stdout, stderr, err = executeCtx(ctx, shell, "scoop install adoptopenjdk-hotspot")
 	if err != nil {
 		return fmt.Errorf("unable to install java using scoop. %v", err)
 	}
@@ -107,7 +160,7 @@ func installJava() error {
 	log.Infof("installing java using scoop. stdout: %s", stdout)
 
 	// clean up excessive dependencies
-	stdout, stderr, err = shell.Execute("scoop uninstall git")
+	stdout, stderr, err = executeCtx(ctx, shell, "scoop uninstall git")
 	if err != nil {
 		return fmt.Errorf("unable to uninstall git using scoop. %v", err)
 	}
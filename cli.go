@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/grvlle/molly_installer/backend/install"
+)
+
+// command is a subcommand that runs without starting the GUI
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+var commands = []command{
+	{
+		name:  "install",
+		usage: "install Molly Wallet without the GUI",
+		run:   runHeadlessInstall,
+	},
+	{
+		name:  "release-info",
+		usage: "show the release the next installation installs",
+		run:   releaseInfo,
+	},
+	{
+		name:  "apply-update",
+		usage: "install a downloaded Molly Wallet package, e.g apply-update -from mollywallet.zip -version 1.2.0",
+		run:   applyUpdate,
+	},
+	{
+		name:  "check-updates",
+		usage: "compare the installed version with the latest release, -schedule runs it daily",
+		run:   checkUpdates,
+	},
+	{
+		name:  "cache",
+		usage: "manage the download cache, \"cache clean\" removes every cached download",
+		run:   cache,
+	},
+	{
+		name:  "export-diagnostics",
+		usage: "bundle the install and wallet logs for bug reports",
+		run:   exportDiagnostics,
+	},
+}
+
+// runCommand runs the subcommand named by args[0] and returns the exit code
+func runCommand(args []string) int {
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			err := cmd.run(args[1:])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", cmd.name, err)
+				return 1
+			}
+			return 0
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown command: %s\n\n", args[0])
+	usage()
+	return 2
+}
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [command]\n\nRuns the graphical installer when no command is given.\n\nCommands:\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(flag.CommandLine.Output(), "  %-20s %s\n", cmd.name, cmd.usage)
+	}
+	fmt.Fprintf(flag.CommandLine.Output(), "\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+func exportDiagnostics(args []string) error {
+	fs := flag.NewFlagSet("export-diagnostics", flag.ExitOnError)
+	output := fs.String("o", "", "path of the diagnostics bundle (default: ~/molly_diagnostics_<time>.zip)")
+	fs.Parse(args)
+
+	bundle, err := installer.ExportDiagnostics(*output)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Diagnostics written to %s\n", bundle)
+	return nil
+}
+
+func runHeadlessInstall(args []string) error {
+	fs := flag.NewFlagSet("install", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "write every installer event to stdout as a JSON object per line")
+	channel := fs.String("channel", "", "release channel to install from and remember: stable, beta or nightly (default: the remembered channel)")
+	fs.Parse(args)
+
+	if *channel != "" {
+		err := installer.SetChannel(*channel)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !*asJSON {
+		// failures show up again in the preflight checks, so they're not reported here
+		if info, err := installer.GetReleaseInfo(); err == nil {
+			fmt.Println(info.Summary())
+		}
+	}
+	installer.SetEventWriter(os.Stdout, *asJSON)
+
+	installer.Run(interruptContext())
+
+	state := installer.GetState()
+	if state.State != install.StateDone {
+		return fmt.Errorf("installation ended in state %s: %s", state.State, state.Error)
+	}
+	return nil
+}
+
+func applyUpdate(args []string) error {
+	fs := flag.NewFlagSet("apply-update", flag.ExitOnError)
+	from := fs.String("from", "", "the Molly Wallet package to install (required)")
+	checksum := fs.String("checksum", "", "the checksum file of the package (default: checksum.sha256 next to the package)")
+	version := fs.String("version", "", "the version of the package, recorded in the install manifest (required)")
+	launch := fs.Bool("launch", true, "start Molly Wallet once the update is applied")
+	asJSON := fs.Bool("json", false, "write every installer event to stdout as a JSON object per line")
+	fs.Parse(args)
+
+	if *from == "" {
+		return fmt.Errorf("-from is required")
+	}
+	if *version == "" {
+		return fmt.Errorf("-version is required")
+	}
+	installer.SetEventWriter(os.Stdout, *asJSON)
+
+	return installer.ApplyUpdate(interruptContext(), install.UpdateOptions{
+		Archive:  *from,
+		Checksum: *checksum,
+		Version:  *version,
+		Launch:   *launch,
+	})
+}
+
+func checkUpdates(args []string) error {
+	fs := flag.NewFlagSet("check-updates", flag.ExitOnError)
+	policy := fs.String("policy", "notify", "what to do when an update is available: notify shows a desktop notification, auto installs it")
+	schedule := fs.Bool("schedule", false, "check for updates daily with the given policy, as a systemd user timer on linux, a launchd agent on macOS or a scheduled task on windows")
+	unschedule := fs.Bool("unschedule", false, "stop the daily update checks")
+	asJSON := fs.Bool("json", false, "print the result as JSON")
+	fs.Parse(args)
+
+	p, err := install.ParseUpdatePolicy(*policy)
+	if err != nil {
+		return err
+	}
+
+	var result interface{}
+	switch {
+	case *schedule || *unschedule:
+		var s *install.Schedule
+		if *schedule {
+			s, err = installer.ScheduleUpdateChecks(p)
+		} else {
+			s, err = installer.UnscheduleUpdateChecks()
+		}
+		if s != nil && !*asJSON {
+			for _, file := range s.Files {
+				fmt.Println(file)
+			}
+			if s.Next != "" {
+				fmt.Println(s.Next)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		result = s
+	default:
+		check, err := installer.RunUpdateCheck(interruptContext(), p)
+		if err != nil {
+			return err
+		}
+		if !*asJSON {
+			fmt.Println(check.Summary())
+		}
+		result = check
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	}
+	return nil
+}
+
+// runSilentInstall installs as configured by the answers, prints the summary and returns the
+// exit code
+func runSilentInstall(answers *install.Answers) int {
+	summary, err := installer.SilentInstall(interruptContext(), answers)
+	if summary != nil {
+		fmt.Print(summary.Summary())
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "silent install: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func releaseInfo(args []string) error {
+	fs := flag.NewFlagSet("release-info", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the release as JSON")
+	fs.Parse(args)
+
+	info, err := installer.GetReleaseInfo()
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(info)
+	}
+	fmt.Print(info.Summary())
+	return nil
+}
+
+func cache(args []string) error {
+	if len(args) != 1 || args[0] != "clean" {
+		return fmt.Errorf("expected a subcommand: clean")
+	}
+	removed, err := installer.CleanCache()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Removed %s\n", removed.Summary())
+	return nil
+}
+
+// interruptContext returns a context cancelled on Ctrl+C, which rolls back the completed steps
+// of a running installation
+func interruptContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt)
+	go func() {
+		<-sigCh
+		cancel()
+	}()
+	return ctx
+}
@@ -1,6 +1,7 @@
 import Vue from "vue";
 import Router from "vue-router";
 import Home from "@/views/Home";
+import Confirm from "@/views/Confirm";
 import Install from "@/views/Install";
 import Uninstall from "@/views/Uninstall";
 
@@ -14,6 +15,11 @@ const router = new Router({
       name: "Home",
       component: Home,
     },
+    {
+      path: "/confirm",
+      name: "Confirm",
+      component: Confirm,
+    },
     {
       path: "/install",
       name: "Install",
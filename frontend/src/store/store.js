@@ -6,6 +6,8 @@ export const store = new Vuex.Store({
     state: {
         progressMsg: "Installing Molly Wallet...",
         progressPercent: "0",
+        installState: "Idle",
+        channel: "stable",
         showSuccessNotification: false,
         showErrorNotification: false,
         successTitle: "",
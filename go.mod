@@ -1,10 +1,9 @@
 module github.com/grvlle/molly_installer
 
-go 1.14
+go 1.16
 
 require (
-	github.com/Masterminds/semver v1.5.0 // indirect
-	github.com/artdarek/go-unzip v1.0.0
+	github.com/Masterminds/semver v1.5.0
 	github.com/bhendo/go-powershell v0.0.0-20190719160123-219e7fb4e41e
 	github.com/fatih/color v1.9.0 // indirect
 	github.com/go-ole/go-ole v1.2.4
@@ -17,9 +16,10 @@ require (
 	github.com/otiai10/copy v1.2.0
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/sirupsen/logrus v1.6.0
+	github.com/ulikunitz/xz v0.5.14
 	github.com/wailsapp/wails v1.7.0-pre1
 	golang.org/x/net v0.0.0-20200602114024-627f9648deb9 // indirect
 	golang.org/x/sys v0.0.0-20200610111108-226ff32320da // indirect
 	golang.org/x/text v0.3.2 // indirect
-	gopkg.in/yaml.v3 v3.0.0-20200605160147-a5ece683394c // indirect
+	gopkg.in/yaml.v3 v3.0.0-20200605160147-a5ece683394c
 )
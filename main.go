@@ -1,29 +1,83 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"os"
-	"path"
 
 	"github.com/grvlle/molly_installer/backend/install"
 	"github.com/leaanthony/mewn"
-	log "github.com/sirupsen/logrus"
 	"github.com/wailsapp/wails"
 )
 
 var installer *install.Install
 
 func init() {
-	var err error
+	install.InitLogger() // log to $HOME/molly_wallet_install.log
+}
 
-	initLogger() // log to $HOME/install.log
+func main() {
+
+	closeRunning := flag.Bool("close-running", false, "close a running Molly Wallet without prompting")
+	installDir := flag.String("install-dir", "", "folder to install Molly Wallet to (default ~/.dag)")
+	proxy := flag.String("proxy", "", "proxy URL for all requests, credentials are read from $MOLLY_PROXY_USER and $MOLLY_PROXY_PASSWORD (default $MOLLY_PROXY, then $HTTPS_PROXY)")
+	caBundle := flag.String("ca-bundle", "", "PEM file of certificates to trust in addition to the system ones (default $MOLLY_CA_BUNDLE)")
+	minTLS := flag.String("min-tls", "", "minimum TLS version, 1.2 or 1.3 (default $MOLLY_MIN_TLS_VERSION or 1.2)")
+	selfUpdate := flag.Bool("self-update", false, "replace the installer with its latest release before installing. Only its checksum is verified, not who published it")
+	silent := flag.String("silent", "", "install unattended as configured by the given YAML or JSON answers file, without the GUI and without prompting")
+	flag.Usage = usage
+	flag.Parse()
+
+	var answers *install.Answers
+	if *silent != "" {
+		var err error
+		answers, err = install.LoadAnswers(*silent)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		if *installDir == "" {
+			*installDir = answers.InstallDir
+		}
+	}
 
-	installer, err = install.Init()
+	var err error
+	installer, err = install.Init(install.Config{
+		InstallDir:    *installDir,
+		ProxyURL:      *proxy,
+		CABundle:      *caBundle,
+		MinTLSVersion: *minTLS,
+	})
 	if err != nil {
 		panic(err)
 	}
-}
+	installer.SetCloseRunningApps(*closeRunning)
 
-func main() {
+	// Replace the installer with a newer build before installing if asked to, the other commands
+	// don't need the latest installer and shouldn't wait for the release feed
+	installing := flag.NArg() == 0 || flag.Arg(0) == "install"
+	if answers != nil && answers.SelfUpdate {
+		*selfUpdate = true
+	}
+	if installing && *selfUpdate && !install.Relaunched() {
+		updated, err := installer.SelfUpdate(context.Background())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to update the installer: %v\n", err)
+		}
+		if updated {
+			err = install.Relaunch()
+			fmt.Fprintf(os.Stderr, "Unable to start the updated installer: %v\n", err)
+		}
+	}
+
+	// Unattended installs and subcommands run without the GUI
+	if answers != nil {
+		os.Exit(runSilentInstall(answers))
+	}
+	if flag.NArg() > 0 {
+		os.Exit(runCommand(flag.Args()))
+	}
 
 	js := mewn.String("./frontend/dist/app.js")
 	css := mewn.String("./frontend/dist/app.css")
@@ -47,27 +101,10 @@ func main() {
 
 // Called from frontend
 func runInstaller() {
-	installer.Run()
+	installer.Run(context.Background())
 }
 
 // Called from frontend
 func runUninstaller() {
 	installer.Uninstall()
 }
-
-func initLogger() {
-	userDir, _ := os.UserHomeDir()
-
-	// initialize update.log file and set log output to file
-	file, err := os.OpenFile(path.Join(userDir, "molly_wallet_install.log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-	if err == nil {
-		log.SetOutput(file)
-	} else {
-		log.Info("Failed to log to file, using default stderr")
-	}
-
-	// Only log the warning severity or above.
-	log.SetLevel(log.InfoLevel)
-
-	log.Infoln("--------------------------------- Logger Initialized -------------------------------------")
-}